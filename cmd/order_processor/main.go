@@ -48,7 +48,7 @@ func main() {
 		log.Println("err:", err)
 	}
 
-	balance, ok := proc.GetBalance(1)
+	balance, ok := proc.GetBalance(1, "USD")
 	if ok {
 		fmt.Println("User 1 balance:", balance)
 	}
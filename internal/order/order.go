@@ -1,11 +1,145 @@
 package order
 
+// Kind is the direction in which an order moves the user's balance.
+type Kind int
+
+const (
+	// KindCredit adds the order amount to the user's balance. It is the zero Kind.
+	KindCredit Kind = iota
+	// KindDebit subtracts the order amount from the user's balance,
+	// as long as the balance stays within the user's overdraft limit.
+	KindDebit
+	// KindTransfer moves the order amount from the user's balance to the balance of the To user,
+	// as long as the sender's balance stays within their overdraft limit.
+	KindTransfer
+	// KindAuthorize moves the order amount from the user's available balance to a hold
+	// identified by the order ID, as long as the balance stays within the user's overdraft limit.
+	KindAuthorize
+	// KindCapture spends the order amount of the hold identified by HoldID and returns
+	// the rest of the hold to the user's available balance. A zero amount captures the whole hold.
+	KindCapture
+	// KindVoid returns the whole hold identified by HoldID to the user's available balance.
+	KindVoid
+	// KindRefund reverses the order amount of the applied credit or debit order identified
+	// by OriginalOrderID. A zero amount refunds whatever has not been refunded yet.
+	KindRefund
+)
+
+// String returns the name of the kind, e.g. "credit".
+func (k Kind) String() string {
+	switch k {
+	case KindCredit:
+		return "credit"
+	case KindDebit:
+		return "debit"
+	case KindTransfer:
+		return "transfer"
+	case KindAuthorize:
+		return "authorize"
+	case KindCapture:
+		return "capture"
+	case KindVoid:
+		return "void"
+	case KindRefund:
+		return "refund"
+	default:
+		return "unknown"
+	}
+}
+
+// Priority tells how urgently an order should be applied relative to other orders.
+type Priority int
+
+const (
+	// PriorityLow is for orders that may wait behind all others, such as bulk imports.
+	PriorityLow Priority = iota - 1
+	// PriorityNormal is the zero Priority.
+	PriorityNormal
+	// PriorityHigh is for orders that must jump ahead of the others, such as refunds and manual adjustments.
+	PriorityHigh
+)
+
+// String returns the name of the priority, e.g. "high".
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
 // Order represents a customer order with user and payment information.
 type Order struct {
 	// ID is the unique identifier for the order.
 	ID int
 	// UserID is the identifier of the user placing the order.
 	UserID int
+	// Kind tells whether the order credits or debits the user's balance.
+	Kind Kind
 	// Amount is the order amount in the smallest currency unit (e.g., cents).
 	Amount int
+	// Currency is the ISO-4217 code of the currency of the amount, e.g. "EUR".
+	Currency string
+	// To is the identifier of the user receiving the amount of a transfer order.
+	// It is unused by other kinds.
+	To int
+	// HoldID is the ID of the authorize order whose hold a capture or void order settles.
+	// It is unused by other kinds.
+	HoldID int
+	// OriginalOrderID is the ID of the order that a refund order reverses.
+	// It is unused by other kinds.
+	OriginalOrderID int
+	// Priority tells how urgently the order should be applied. Users with pending orders
+	// of a higher priority are served first.
+	Priority Priority
+	// Reorderable allows the order to be applied ahead of the pending orders of its user
+	// that have a lower priority. Other orders keep their submission order.
+	// Transfers always keep their place, since they are queued for two users,
+	// and a refund is never applied ahead of its original order.
+	Reorderable bool
+}
+
+// Refund describes reversing all or part of a previously applied order.
+type Refund struct {
+	// ID is the unique identifier for the refund.
+	ID int
+	// UserID is the identifier of the user who placed the original order.
+	UserID int
+	// OriginalOrderID is the ID of the order to reverse.
+	OriginalOrderID int
+	// Amount is the refunded amount in the smallest currency unit (e.g., cents).
+	// A zero amount refunds whatever has not been refunded yet.
+	Amount int
+	// Currency is the ISO-4217 code of the currency of the amount.
+	// It must match the currency of the original order.
+	Currency string
+}
+
+// Order returns the refund as an order of KindRefund.
+func (r Refund) Order() Order {
+	return Order{ID: r.ID, UserID: r.UserID, Kind: KindRefund, Amount: r.Amount, Currency: r.Currency, OriginalOrderID: r.OriginalOrderID}
+}
+
+// Transfer describes moving an amount from one user to another.
+type Transfer struct {
+	// ID is the unique identifier for the transfer.
+	ID int
+	// From is the identifier of the sending user.
+	From int
+	// To is the identifier of the receiving user.
+	To int
+	// Amount is the transferred amount in the smallest currency unit (e.g., cents).
+	Amount int
+	// Currency is the ISO-4217 code of the currency of the amount.
+	Currency string
+}
+
+// Order returns the transfer as an order of KindTransfer placed by the sending user.
+func (t Transfer) Order() Order {
+	return Order{ID: t.ID, UserID: t.From, Kind: KindTransfer, Amount: t.Amount, Currency: t.Currency, To: t.To}
 }
@@ -0,0 +1,211 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+)
+
+// BatchResult is the result of submitting a single order of a batch.
+type BatchResult struct {
+	// Future resolves once the order has been processed. It is nil if the order was rejected.
+	Future *OrderFuture
+	// Err is the reason the order was rejected, or nil if it was accepted.
+	Err error
+}
+
+// admitted is an order of a batch that passed validation and deduplication.
+type admitted struct {
+	index       int
+	future      *OrderFuture
+	deduplicate bool
+}
+
+func (o *orderProcessor) SubmitBatch(ctx context.Context, orders []order.Order, opts ...SubmitOption) []BatchResult {
+	cfg := submitConfig{overflowPolicy: o.overflowPolicy, rateLimitPolicy: o.rateLimitPolicy}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	results := make([]BatchResult, len(orders))
+	futures := make([]*OrderFuture, len(orders))
+	for i, ord := range orders {
+		ord.Currency = o.currencyOrDefault(ord.Currency)
+		futures[i] = newOrderFuture(ord, o.clock, o.observe)
+	}
+
+	var err error
+	switch {
+	case !cfg.overflowPolicy.valid():
+		err = ErrOverflowPolicyInvalid
+	case !cfg.rateLimitPolicy.valid():
+		err = ErrRateLimitPolicyInvalid
+	case ctx.Err() != nil:
+		err = fmt.Errorf("%w: %w", ErrSubmitCanceled, ctx.Err())
+	}
+	if err != nil {
+		for i, future := range futures {
+			results[i].Err = o.reject(future, err)
+		}
+		return results
+	}
+
+	o.userQueuesMu.Lock()
+	select {
+	case <-o.shutdownChan:
+		o.userQueuesMu.Unlock()
+		for i, future := range futures {
+			results[i].Err = o.reject(future, ErrProcessorShutdown)
+		}
+		return results
+	default:
+	}
+
+	o.processorWg.Add(1)
+	o.userQueuesMu.Unlock()
+	defer o.processorWg.Done()
+
+	var users []int
+	byUser := make(map[int][]int)
+	for i, ord := range orders {
+		if _, ok := byUser[ord.UserID]; !ok {
+			users = append(users, ord.UserID)
+		}
+		byUser[ord.UserID] = append(byUser[ord.UserID], i)
+	}
+
+	for _, userID := range users {
+		o.submitUserBatch(ctx, cfg, byUser[userID], futures, results)
+	}
+
+	return results
+}
+
+// submitUserBatch submits the orders of a single user at the given batch indexes.
+// The orders that fit into the user's queue are pushed at once, up to the first transfer,
+// which also involves the queue of the receiving user; the rest are enqueued one by one
+// according to the overflow policy, keeping their relative order.
+// Every order is admitted by the rate limits before it is pushed.
+func (o *orderProcessor) submitUserBatch(ctx context.Context, cfg submitConfig, indexes []int, futures []*OrderFuture, results []BatchResult) {
+	pending := make([]admitted, 0, len(indexes))
+	for _, i := range indexes {
+		future := futures[i]
+		if err := validate(future.Order(), o.validators); err != nil {
+			results[i].Err = o.reject(future, err)
+			continue
+		}
+
+		deduplicate := o.dedup != nil && future.Order().ID != 0
+		if deduplicate {
+			if original, duplicate := o.dedup.Reserve(future); duplicate {
+				results[i].Future = original
+				continue
+			}
+		}
+
+		if err := o.throttle(ctx, future.Order().UserID, cfg.rateLimitPolicy); err != nil {
+			results[i].Err = o.fail(admitted{future: future, deduplicate: deduplicate}, err)
+			continue
+		}
+
+		o.registry.add(future)
+		pending = append(pending, admitted{index: i, future: future, deduplicate: deduplicate})
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	fast := pending
+	for i, a := range pending {
+		if a.future.Order().Kind == order.KindTransfer {
+			fast = pending[:i]
+			break
+		}
+	}
+
+	pushed, err := o.pushAll(fast)
+	for _, a := range pending[:pushed] {
+		if err != nil {
+			results[a.index].Err = o.fail(a, err)
+			continue
+		}
+		results[a.index].Future = a.future
+	}
+
+	for _, a := range pending[pushed:] {
+		if err := o.enqueue(ctx, a.future, cfg.overflowPolicy); err != nil {
+			results[a.index].Err = o.fail(a, err)
+			continue
+		}
+		results[a.index].Future = a.future
+	}
+}
+
+// pushAll pushes as many of the admitted orders of a single user as fit into the user's queue
+// and the free in-flight slots, without waiting, and hands the queue to the worker pool if it was idle.
+// Returns the number of pushed orders, which are always a prefix of the admitted ones.
+func (o *orderProcessor) pushAll(pending []admitted) (int, error) {
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	slots := o.tryAcquire(len(pending))
+	if slots == 0 {
+		return 0, nil
+	}
+
+	batch := make([]*OrderFuture, slots)
+	for i := range batch {
+		batch[i] = pending[i].future
+	}
+
+	o.userQueuesMu.Lock()
+	queue := o.queueOf(batch[0].Order().UserID)
+	pushed, schedule := queue.PushAll(batch)
+	o.userQueuesMu.Unlock()
+
+	for range slots - pushed {
+		o.release()
+	}
+
+	for _, future := range batch[:pushed] {
+		o.metrics.OrderQueued(future.Order())
+	}
+
+	if schedule {
+		return pushed, o.schedule(queue)
+	}
+
+	priority := order.PriorityLow
+	for _, future := range batch[:pushed] {
+		priority = max(priority, future.Order().Priority)
+	}
+	o.scheduler.boost(queue, priority)
+	return pushed, nil
+}
+
+// tryAcquire takes up to n in-flight slots without waiting and returns how many it took.
+func (o *orderProcessor) tryAcquire(n int) int {
+	if o.inFlight == nil {
+		return n
+	}
+
+	for i := range n {
+		select {
+		case o.inFlight <- struct{}{}:
+		default:
+			return i
+		}
+	}
+	return n
+}
+
+// fail rejects an admitted order that could not be queued and releases its order ID
+// for deduplication, so that it can be submitted again. Returns err.
+func (o *orderProcessor) fail(a admitted, err error) error {
+	if a.deduplicate {
+		o.dedup.Release(a.future)
+	}
+	return o.reject(a.future, err)
+}
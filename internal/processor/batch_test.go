@@ -0,0 +1,164 @@
+package processor_test
+
+import (
+	"context"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("SubmitBatch", Label("unit"), func() {
+	var (
+		ctrl *gomock.Controller
+		s    *mock.MockUserStorage
+		pool *mock.MockWorkerPool
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		s = mock.NewMockUserStorage(ctrl)
+		pool = mock.NewMockWorkerPool(ctrl)
+	})
+
+	It("should return a result for every order in submission order", func() {
+		pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(2)
+		proc, err := processor.NewOrderProcessor(s, pool)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		orders := []order.Order{
+			{ID: 1, UserID: 1, Amount: 100},
+			{ID: 2, UserID: 2, Amount: 0},
+			{ID: 3, UserID: 2, Amount: 50},
+			{ID: 4, UserID: 1, Amount: 25},
+		}
+		results := proc.SubmitBatch(context.Background(), orders)
+		Expect(results).To(HaveLen(len(orders)), "there should be one result per order")
+
+		Expect(results[1].Err).To(MatchError(processor.ErrOrderInvalid), "the invalid order should be rejected")
+		Expect(results[1].Future).To(BeNil(), "a rejected order should have no future")
+		for _, i := range []int{0, 2, 3} {
+			Expect(results[i].Err).NotTo(HaveOccurred(), "valid order %d should be accepted", orders[i].ID)
+			Expect(results[i].Future.Order().ID).To(Equal(orders[i].ID), "result %d should belong to its order", i)
+		}
+	})
+
+	It("should queue the orders of a user in their relative order", func() {
+		pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(1)
+		proc, err := processor.NewOrderProcessor(s, pool)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		results := proc.SubmitBatch(context.Background(), []order.Order{
+			{ID: 3, UserID: 1, Amount: 100},
+			{ID: 1, UserID: 1, Amount: 100},
+			{ID: 2, UserID: 1, Amount: 100},
+		})
+		for _, result := range results {
+			Expect(result.Err).NotTo(HaveOccurred(), "every order should be accepted")
+		}
+
+		orders := proc.ListOrders(1, processor.OrderFilter{})
+		Expect(orders).To(HaveLen(3), "all orders should be tracked")
+		Expect([]int{orders[0].Order.ID, orders[1].Order.ID, orders[2].Order.ID}).To(Equal([]int{3, 1, 2}), "orders should keep their batch order")
+	})
+
+	It("should reject only the orders that do not fit into a full queue", func() {
+		pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(1)
+		proc, err := processor.NewOrderProcessor(s, pool, processor.WithQueueCapacity(2))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		results := proc.SubmitBatch(context.Background(), []order.Order{
+			{ID: 1, UserID: 1, Amount: 100},
+			{ID: 2, UserID: 1, Amount: 100},
+			{ID: 3, UserID: 1, Amount: 100},
+		}, processor.OnOverflow(processor.OverflowReject))
+
+		Expect(results[0].Err).NotTo(HaveOccurred(), "the first order should fit into the queue")
+		Expect(results[1].Err).NotTo(HaveOccurred(), "the second order should fit into the queue")
+		Expect(results[2].Err).To(MatchError(processor.ErrQueueFull), "the third order should not fit into the queue")
+	})
+
+	It("should reject every order after shutdown", func() {
+		pool.EXPECT().Shutdown()
+		pool.EXPECT().Wait()
+		proc, err := processor.NewOrderProcessor(s, pool)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+		proc.Shutdown()
+
+		results := proc.SubmitBatch(context.Background(), []order.Order{
+			{ID: 1, UserID: 1, Amount: 100},
+			{ID: 2, UserID: 2, Amount: 100},
+		})
+		for _, result := range results {
+			Expect(result.Err).To(MatchError(processor.ErrProcessorShutdown), "orders submitted after shutdown should be rejected")
+		}
+	})
+})
+
+var _ = Describe("SubmitBatch E2E", Label("e2e"), func() {
+	It("should apply every accepted order of the batch", func() {
+		s := storage.NewStorage()
+		pool, err := worker.NewWorkerPool(4, 100)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(s, pool, processor.WithHandler(processor.NewDelayedHandler(0)))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		var orders []order.Order
+		for i := range 30 {
+			orders = append(orders, order.Order{ID: i + 1, UserID: i%3 + 1, Amount: i + 1})
+		}
+
+		results := proc.SubmitBatch(context.Background(), orders)
+		for _, result := range results {
+			Expect(result.Err).NotTo(HaveOccurred(), "every order should be accepted")
+			outcome, err := result.Future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+			Expect(outcome.Status).To(Equal(processor.StatusApplied), "every order should be applied")
+		}
+		proc.Shutdown()
+
+		for userID, expected := range map[int]int{1: 145, 2: 155, 3: 165} {
+			balance, _ := s.Get(userID, "USD")
+			Expect(balance).To(Equal(expected), "user %d balance should include all of their orders", userID)
+		}
+	})
+
+	It("should fail the debits a user cannot cover and apply the rest", func() {
+		s := storage.NewStorage()
+		pool, err := worker.NewWorkerPool(2, 10)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(s, pool, processor.WithHandler(processor.NewDelayedHandler(0)))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+		Expect(proc.SetOverdraftLimit(1, "USD", 20)).To(Succeed(), "setting an overdraft limit should succeed")
+
+		results := proc.SubmitBatch(context.Background(), []order.Order{
+			{ID: 1, UserID: 1, Amount: 100},
+			{ID: 2, UserID: 1, Kind: order.KindDebit, Amount: 110},
+			{ID: 3, UserID: 1, Kind: order.KindDebit, Amount: 20},
+			{ID: 4, UserID: 1, Kind: order.KindDebit, Amount: 5},
+		})
+
+		var statuses []processor.Status
+		for _, result := range results {
+			Expect(result.Err).NotTo(HaveOccurred(), "every order should be accepted")
+			outcome, err := result.Future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+			statuses = append(statuses, outcome.Status)
+		}
+		proc.Shutdown()
+
+		Expect(statuses).To(Equal([]processor.Status{
+			processor.StatusApplied, processor.StatusApplied, processor.StatusFailed, processor.StatusApplied,
+		}), "only the debit beyond the overdraft limit should fail")
+		Expect(results[2].Future.Status().Err).To(MatchError(processor.ErrInsufficientFunds), "the failed debit should report insufficient funds")
+
+		balance, _ := s.Get(1, "USD")
+		Expect(balance).To(Equal(-15), "the balance should reflect the applied orders only")
+	})
+})
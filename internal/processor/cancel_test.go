@@ -0,0 +1,109 @@
+package processor_test
+
+import (
+	"context"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("Order cancellation", Label("unit"), func() {
+	When("cancelling a pending order", func() {
+		It("should remove it from the queue and resolve its future as cancelled", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+			pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(1)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			first, err := proc.SubmitAsync(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100})
+			Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+			_, err = proc.SubmitAsync(context.Background(), order.Order{ID: 2, UserID: 1, Amount: 100})
+			Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+
+			err = proc.Cancel(1)
+			Expect(err).NotTo(HaveOccurred(), "cancelling a pending order should not return an error")
+
+			outcome, err := first.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for a resolved future should not return an error")
+			Expect(outcome.Status).To(Equal(processor.StatusCancelled), "order should be cancelled")
+			Expect(outcome.Err).To(MatchError(processor.ErrOrderCancelled), "outcome should carry ErrOrderCancelled")
+
+			err = proc.Cancel(1)
+			Expect(err).To(MatchError(processor.ErrOrderFinished), "cancelling a cancelled order should return ErrOrderFinished")
+		})
+
+		It("should return ErrOrderNotFound for an unknown order", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Cancel(42)
+			Expect(err).To(MatchError(processor.ErrOrderNotFound), "cancelling an unknown order should return ErrOrderNotFound")
+		})
+	})
+
+	When("removing orders from a user queue", func() {
+		It("should remove only pending orders and keep the rest in order", func() {
+			queue := processor.NewUserQueue(10, 0)
+			futures := make([]*processor.OrderFuture, 3)
+			for i := range futures {
+				futures[i] = processor.NewOrderFuture(order.Order{ID: i + 1, UserID: 1, Amount: 100})
+				queue.Push(futures[i])
+			}
+
+			Expect(queue.Remove(futures[1])).To(BeTrue(), "pending order should be removed")
+			Expect(queue.Remove(futures[1])).To(BeFalse(), "removed order should not be removed twice")
+
+			next, _ := queue.Pop()
+			Expect(next).To(BeIdenticalTo(futures[0]), "first order should stay at the head")
+			Expect(queue.Remove(futures[0])).To(BeFalse(), "popped order should not be removable")
+
+			next, _ = queue.Pop()
+			Expect(next).To(BeIdenticalTo(futures[2]), "third order should follow the first")
+		})
+	})
+})
+
+var _ = Describe("Order cancellation E2E", Label("e2e"), func() {
+	It("should refuse to cancel an order a worker is processing", func() {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		handler := processor.OrderHandlerFunc(func(_ context.Context, ord order.Order, s storage.Storage) (int, error) {
+			close(started)
+			<-release
+			return s.Add(ord.UserID, ord.Currency, ord.Amount)
+		})
+
+		s := storage.NewStorage()
+		pool, err := worker.NewWorkerPool(1, 10)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(s, pool, processor.WithHandler(handler))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		err = proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})
+		Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+		Eventually(started).Should(BeClosed(), "worker should start processing the order")
+
+		err = proc.Cancel(1)
+		Expect(err).To(MatchError(processor.ErrOrderInFlight), "cancelling an in-flight order should return ErrOrderInFlight")
+
+		close(release)
+		proc.Shutdown()
+
+		amount, _ := s.Get(1, "USD")
+		Expect(amount).To(Equal(100), "in-flight order should still be applied")
+	})
+})
@@ -0,0 +1,16 @@
+package processor
+
+import "time"
+
+// Clock provides the current time to the processor.
+// It is used for order timestamps and to measure how long user queues stay idle.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+)
+
+// Conversion describes how an order was settled into its user's account currency.
+type Conversion struct {
+	// Rate is the exchange rate applied, in whole units of Currency per whole unit of SourceCurrency.
+	Rate *big.Rat
+	// RateSource is where the rate came from, as reported by the RateProvider.
+	RateSource string
+	// SourceAmount is the amount of the submitted order, in minor units of SourceCurrency.
+	SourceAmount int
+	// SourceCurrency is the currency of the submitted order.
+	SourceCurrency string
+	// Amount is the converted amount applied to the balance, in minor units of Currency.
+	Amount int
+	// Currency is the account currency of the user.
+	Currency string
+	// Rounding is the rounding mode used to round Amount to a minor unit of Currency.
+	Rounding RoundingMode
+}
+
+// minorUnits lists the ISO-4217 currencies whose minor unit is not a hundredth.
+var minorUnits = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3,
+	"JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0,
+	"TND": 3, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// minorUnitExponent returns the number of decimal places of the minor unit of the currency.
+func minorUnitExponent(currency string) int {
+	if exp, ok := minorUnits[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// converter settles credits and debits into the account currency of their users.
+type converter struct {
+	provider RateProvider
+	rounding RoundingMode
+
+	mu       sync.RWMutex
+	accounts map[int]string
+}
+
+func newConverter() *converter {
+	return &converter{
+		rounding: RoundHalfEven,
+		accounts: make(map[int]string),
+	}
+}
+
+// setAccount sets the account currency of the user. An empty currency removes it.
+func (c *converter) setAccount(userID int, currency string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if currency == "" {
+		delete(c.accounts, userID)
+		return
+	}
+	c.accounts[userID] = currency
+}
+
+// account returns the account currency of the user, if one is set.
+func (c *converter) account(userID int) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	currency, ok := c.accounts[userID]
+	return currency, ok
+}
+
+// convert returns the order settled into the account currency of its user, along with how it
+// was converted. Orders other than credits and debits, orders of users without an account
+// currency and orders already in it are returned unchanged with a nil conversion.
+// Returns ErrRateNotFound if no rate provider is configured or it has no rate for the pair,
+// or ErrConversionOverflow if the converted amount does not fit into an int.
+func (c *converter) convert(ctx context.Context, ord order.Order) (order.Order, *Conversion, error) {
+	if ord.Kind != order.KindCredit && ord.Kind != order.KindDebit {
+		return ord, nil, nil
+	}
+
+	currency, ok := c.account(ord.UserID)
+	if !ok || currency == ord.Currency {
+		return ord, nil, nil
+	}
+
+	if c.provider == nil {
+		return ord, nil, fmt.Errorf("%w: %s to %s: no rate provider configured", ErrRateNotFound, ord.Currency, currency)
+	}
+
+	rate, err := c.provider.Rate(ctx, ord.Currency, currency)
+	if err != nil {
+		return ord, nil, err
+	}
+	if rate.Value == nil || rate.Value.Sign() <= 0 {
+		return ord, nil, fmt.Errorf("%w: %s to %s from %s is not positive", ErrRateInvalid, ord.Currency, currency, rate.Source)
+	}
+
+	// amount * rate, rescaled from minor units of the source currency to minor units of the target.
+	amount := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(ord.Amount)), rate.Value)
+	scale := minorUnitExponent(currency) - minorUnitExponent(ord.Currency)
+	pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(scale))), nil))
+	if scale >= 0 {
+		amount.Mul(amount, pow)
+	} else {
+		amount.Quo(amount, pow)
+	}
+
+	converted := c.rounding.round(amount)
+	if !converted.IsInt64() || int64(int(converted.Int64())) != converted.Int64() {
+		return ord, nil, fmt.Errorf("%w: %d %s to %s", ErrConversionOverflow, ord.Amount, ord.Currency, currency)
+	}
+
+	conversion := &Conversion{
+		Rate:           rate.Value,
+		RateSource:     rate.Source,
+		SourceAmount:   ord.Amount,
+		SourceCurrency: ord.Currency,
+		Amount:         int(converted.Int64()),
+		Currency:       currency,
+		Rounding:       c.rounding,
+	}
+
+	ord.Amount = conversion.Amount
+	ord.Currency = currency
+	return ord, conversion, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
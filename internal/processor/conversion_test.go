@@ -0,0 +1,148 @@
+package processor_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+)
+
+var _ = Describe("Rate providers", Label("unit"), func() {
+	It("should serve the static rates with their source", func() {
+		provider, err := processor.NewStaticRateProvider("test", map[processor.CurrencyPair]string{
+			{From: "EUR", To: "USD"}: "1.0842",
+		})
+		Expect(err).NotTo(HaveOccurred(), "creating provider should not return an error")
+
+		rate, err := provider.Rate(context.Background(), "EUR", "USD")
+		Expect(err).NotTo(HaveOccurred(), "a known rate should be found")
+		Expect(rate.Value.RatString()).To(Equal("5421/5000"), "the rate should be parsed exactly")
+		Expect(rate.Source).To(Equal("test"), "the rate should report the provider's source")
+
+		_, err = provider.Rate(context.Background(), "USD", "EUR")
+		Expect(err).To(MatchError(processor.ErrRateNotFound), "the inverse rate should not be derived")
+	})
+
+	DescribeTable("creating a static provider with an invalid rate",
+		func(pair processor.CurrencyPair, rate string) {
+			provider, err := processor.NewStaticRateProvider("test", map[processor.CurrencyPair]string{pair: rate})
+			Expect(provider).To(BeNil(), "provider should be nil when a rate is invalid")
+			Expect(err).To(MatchError(processor.ErrRateInvalid), "creating provider should return ErrRateInvalid")
+		},
+		Entry("zero rate", processor.CurrencyPair{From: "EUR", To: "USD"}, "0"),
+		Entry("negative rate", processor.CurrencyPair{From: "EUR", To: "USD"}, "-1.1"),
+		Entry("malformed rate", processor.CurrencyPair{From: "EUR", To: "USD"}, "1,1"),
+		Entry("invalid currency", processor.CurrencyPair{From: "eur", To: "USD"}, "1.1"),
+	)
+
+	It("should load rates from a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "rates.txt")
+		Expect(os.WriteFile(path, []byte("# daily rates\n\nEUR USD 1.0842\nUSD JPY 151.2\n"), 0o600)).To(Succeed(), "writing rate file should succeed")
+
+		provider, err := processor.LoadRateFile(path)
+		Expect(err).NotTo(HaveOccurred(), "loading a valid rate file should not return an error")
+
+		rate, err := provider.Rate(context.Background(), "USD", "JPY")
+		Expect(err).NotTo(HaveOccurred(), "a rate from the file should be found")
+		Expect(rate.Value.Cmp(big.NewRat(1512, 10))).To(BeZero(), "the rate should be parsed exactly")
+		Expect(rate.Source).To(Equal(path), "the rate should report the file as its source")
+	})
+
+	It("should report the line of an invalid rate in a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "rates.txt")
+		Expect(os.WriteFile(path, []byte("EUR USD 1.0842\nUSD JPY\n"), 0o600)).To(Succeed(), "writing rate file should succeed")
+
+		_, err := processor.LoadRateFile(path)
+		Expect(err).To(MatchError(processor.ErrRateInvalid), "loading an invalid rate file should return ErrRateInvalid")
+		Expect(err.Error()).To(ContainSubstring(path+":2"), "the error should point at the invalid line")
+	})
+})
+
+var _ = Describe("Currency conversion E2E", Label("e2e"), func() {
+	var provider processor.RateProvider
+
+	BeforeEach(func() {
+		var err error
+		provider, err = processor.NewStaticRateProvider("test", map[processor.CurrencyPair]string{
+			{From: "EUR", To: "USD"}: "1.005",
+			{From: "GBP", To: "USD"}: "1.015",
+			{From: "USD", To: "JPY"}: "151.2",
+		})
+		Expect(err).NotTo(HaveOccurred(), "creating provider should not return an error")
+	})
+
+	It("should settle an order into the account currency and record the conversion", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithRateProvider(provider))
+		Expect(proc.SetAccountCurrency(1, "JPY")).To(Succeed(), "setting a valid account currency should succeed")
+
+		outcome := apply(proc, order.Order{ID: 1, UserID: 1, Amount: 1050, Currency: "USD"})
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "the converted order should be applied")
+		Expect(outcome.Order.Currency).To(Equal("USD"), "the outcome should keep the submitted order")
+		Expect(outcome.Conversion).NotTo(BeNil(), "the conversion should be recorded")
+		Expect(outcome.Conversion.RateSource).To(Equal("test"), "the source of the rate should be recorded")
+		Expect(outcome.Conversion.SourceAmount).To(Equal(1050), "the source amount should be recorded")
+		Expect(outcome.Conversion.SourceCurrency).To(Equal("USD"), "the source currency should be recorded")
+		Expect(outcome.Conversion.Amount).To(Equal(1588), "$10.50 should be 1587.6 yen rounded to 1588")
+		Expect(outcome.Conversion.Currency).To(Equal("JPY"), "the account currency should be recorded")
+		Expect(outcome.Balance).To(Equal(1588), "the balance should be in the account currency")
+
+		balance, ok := proc.GetBalance(1, "JPY")
+		Expect(ok).To(BeTrue(), "the balance in the account currency should exist")
+		Expect(balance).To(Equal(1588), "the converted amount should be applied")
+		_, ok = proc.GetBalance(1, "USD")
+		Expect(ok).To(BeFalse(), "no balance should be kept in the source currency")
+	})
+
+	It("should not convert an order already in the account currency", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithRateProvider(provider))
+		Expect(proc.SetAccountCurrency(1, "USD")).To(Succeed(), "setting a valid account currency should succeed")
+
+		outcome := apply(proc, order.Order{ID: 1, UserID: 1, Amount: 100})
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "the order should be applied")
+		Expect(outcome.Conversion).To(BeNil(), "no conversion should be recorded")
+	})
+
+	It("should fail an order without a rate into the account currency", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithRateProvider(provider))
+		Expect(proc.SetAccountCurrency(1, "EUR")).To(Succeed(), "setting a valid account currency should succeed")
+
+		outcome := apply(proc, order.Order{ID: 1, UserID: 1, Amount: 100})
+		Expect(outcome.Status).To(Equal(processor.StatusFailed), "the order should fail")
+		Expect(outcome.Err).To(MatchError(processor.ErrRateNotFound), "the order should fail with ErrRateNotFound")
+		Expect(proc.GetBalances(1)).To(BeEmpty(), "no balance should be changed")
+	})
+
+	It("should reject an account currency that is not an ISO-4217 code", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithRateProvider(provider))
+		Expect(proc.SetAccountCurrency(1, "usd")).To(MatchError(processor.ErrAccountCurrencyInvalid), "an invalid account currency should be rejected")
+	})
+
+	DescribeTable("rounding converted amounts",
+		func(mode processor.RoundingMode, currency string, amount, expected int) {
+			proc := newTestProcessor(storage.NewStorage(), processor.WithRateProvider(provider), processor.WithRounding(mode))
+			Expect(proc.SetAccountCurrency(1, "USD")).To(Succeed(), "setting a valid account currency should succeed")
+
+			outcome := apply(proc, order.Order{ID: 1, UserID: 1, Amount: amount, Currency: currency})
+			Expect(outcome.Conversion).NotTo(BeNil(), "the conversion should be recorded")
+			Expect(outcome.Conversion.Rounding).To(Equal(mode), "the rounding mode should be recorded")
+			Expect(outcome.Conversion.Amount).To(Equal(expected), "the converted amount should be rounded by the mode")
+		},
+		Entry("half even rounds a tie down to even", processor.RoundHalfEven, "EUR", 100, 100),
+		Entry("half even rounds a tie up to even", processor.RoundHalfEven, "GBP", 100, 102),
+		Entry("half up rounds a tie away from zero", processor.RoundHalfUp, "EUR", 100, 101),
+		Entry("half down rounds a tie toward zero", processor.RoundHalfDown, "EUR", 100, 100),
+		Entry("up rounds away from zero", processor.RoundUp, "EUR", 1000, 1005),
+		Entry("up rounds a fraction away from zero", processor.RoundUp, "EUR", 110, 111),
+		Entry("down truncates", processor.RoundDown, "GBP", 110, 111),
+		Entry("ceiling rounds up", processor.RoundCeiling, "EUR", 110, 111),
+		Entry("floor rounds down", processor.RoundFloor, "GBP", 110, 111),
+	)
+
+})
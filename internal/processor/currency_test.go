@@ -0,0 +1,82 @@
+package processor_test
+
+import (
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("Currencies", Label("unit"), func() {
+	It("should reject an order whose currency is not an ISO-4217 code", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		proc, err := processor.NewOrderProcessor(mock.NewMockUserStorage(ctrl), mock.NewMockWorkerPool(ctrl))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		err = proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100, Currency: "eur"})
+
+		var validationErr *processor.ValidationError
+		Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+		Expect(validationErr.Field).To(Equal("Currency"), "violation should be on Currency")
+		Expect(validationErr.Rule).To(Equal(processor.RuleFormat), "Currency should violate the format rule")
+	})
+
+	It("should look up balances in the default currency when none is given", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		s := mock.NewMockUserStorage(ctrl)
+		s.EXPECT().Get(1, "EUR").Return(100, true).Times(1)
+
+		proc, err := processor.NewOrderProcessor(s, mock.NewMockWorkerPool(ctrl), processor.WithDefaultCurrency("EUR"))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		balance, ok := proc.GetBalance(1, "")
+		Expect(ok).To(BeTrue(), "the balance should be found")
+		Expect(balance).To(Equal(100), "the balance in the default currency should be returned")
+	})
+})
+
+var _ = Describe("Currencies E2E", Label("e2e"), func() {
+	var proc processor.OrderProcessor
+
+	BeforeEach(func() {
+		proc = newTestProcessor(storage.NewStorage())
+	})
+
+	It("should keep a separate balance per currency", func() {
+		outcomes := submitAll(proc,
+			order.Order{ID: 1, UserID: 1, Amount: 100},
+			order.Order{ID: 2, UserID: 1, Amount: 50, Currency: "EUR"},
+			order.Order{ID: 3, UserID: 1, Kind: order.KindDebit, Amount: 60, Currency: "EUR"},
+		)
+
+		Expect(outcomes[0].Order.Currency).To(Equal("USD"), "an order without a currency should be in the default currency")
+		Expect(outcomes[2].Err).To(MatchError(processor.ErrInsufficientFunds), "a debit should only use the balance in its currency")
+		Expect(proc.GetBalances(1)).To(Equal(map[string]storage.Balance{
+			"USD": {Available: 100},
+			"EUR": {Available: 50},
+		}), "all balances of the user should be returned")
+	})
+
+	It("should fail orders that mix currencies with a CurrencyMismatchError", func() {
+		outcomes := submitAll(proc,
+			order.Order{ID: 1, UserID: 1, Amount: 100, Currency: "EUR"},
+			order.Order{ID: 2, UserID: 1, Kind: order.KindAuthorize, Amount: 50, Currency: "EUR"},
+			order.Order{ID: 3, UserID: 1, Kind: order.KindCapture, HoldID: 2, Currency: "USD"},
+			order.Refund{ID: 4, UserID: 1, OriginalOrderID: 1, Currency: "GBP"}.Order(),
+		)
+
+		var mismatch *processor.CurrencyMismatchError
+		Expect(errors.As(outcomes[2].Err, &mismatch)).To(BeTrue(), "capturing a hold in another currency should fail with a CurrencyMismatchError")
+		Expect(mismatch.Expected).To(Equal("EUR"), "the currency of the hold should be reported")
+		Expect(outcomes[3].Err).To(MatchError(processor.ErrCurrencyMismatch), "refunding in another currency should fail")
+
+		balance, _ := proc.GetBalanceDetails(1, "EUR")
+		Expect(balance).To(Equal(storage.Balance{Available: 50, Held: 50}), "failed orders should not change the balance")
+	})
+})
@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"sync"
+	"time"
+)
+
+// DedupStore remembers submitted orders by order ID, so that a retried submit
+// of the same order returns the original future instead of applying the order again.
+// Implementations must be safe for concurrent use.
+type DedupStore interface {
+	// Reserve records the future under the ID of its order unless the ID is already recorded.
+	// Returns the recorded future and true if the ID was already taken.
+	Reserve(future *OrderFuture) (*OrderFuture, bool)
+	// Release forgets the ID of the future's order if it is still recorded for this future.
+	// It is called when the order could not be queued, so that it can be submitted again.
+	Release(future *OrderFuture)
+}
+
+type dedupEntry struct {
+	future    *OrderFuture
+	expiresAt time.Time
+}
+
+type memoryDedupStore struct {
+	mu      sync.Mutex
+	clock   Clock
+	window  time.Duration
+	entries map[int]dedupEntry
+	expiry  []dedupEntry
+}
+
+// NewMemoryDedupStore creates an in-memory DedupStore that remembers an order ID for
+// window after its first submission, measured with clock. A zero window remembers
+// order IDs for the lifetime of the store.
+func NewMemoryDedupStore(window time.Duration, clock Clock) DedupStore {
+	return &memoryDedupStore{
+		clock:   clock,
+		window:  window,
+		entries: make(map[int]dedupEntry),
+	}
+}
+
+func (s *memoryDedupStore) Reserve(future *OrderFuture) (*OrderFuture, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.expire(now)
+
+	id := future.Order().ID
+	if entry, exists := s.entries[id]; exists {
+		return entry.future, true
+	}
+
+	entry := dedupEntry{future: future}
+	if s.window > 0 {
+		entry.expiresAt = now.Add(s.window)
+		s.expiry = append(s.expiry, entry)
+	}
+	s.entries[id] = entry
+
+	return future, false
+}
+
+func (s *memoryDedupStore) Release(future *OrderFuture) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := future.Order().ID
+	if entry, exists := s.entries[id]; exists && entry.future == future {
+		delete(s.entries, id)
+	}
+}
+
+// expire forgets the entries whose window has passed. Entries expire in the order
+// they were reserved, so only the head of the expiry list has to be checked.
+func (s *memoryDedupStore) expire(now time.Time) {
+	for len(s.expiry) > 0 && !now.Before(s.expiry[0].expiresAt) {
+		head := s.expiry[0]
+		s.expiry[0] = dedupEntry{}
+		s.expiry = s.expiry[1:]
+
+		id := head.future.Order().ID
+		if entry, exists := s.entries[id]; exists && entry.future == head.future {
+			delete(s.entries, id)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package processor_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
+)
+
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+var _ = Describe("DedupStore", Label("unit"), func() {
+	When("reserving order IDs in the memory store", func() {
+		It("should return the original future for a duplicate ID until the window passes", func() {
+			clock := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			store := processor.NewMemoryDedupStore(time.Minute, clock)
+
+			original := processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100})
+			recorded, duplicate := store.Reserve(original)
+			Expect(duplicate).To(BeFalse(), "first reservation should not be a duplicate")
+			Expect(recorded).To(BeIdenticalTo(original), "first reservation should record the future")
+
+			retry := processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100})
+			recorded, duplicate = store.Reserve(retry)
+			Expect(duplicate).To(BeTrue(), "retry within the window should be a duplicate")
+			Expect(recorded).To(BeIdenticalTo(original), "retry should get the original future")
+
+			clock.Advance(time.Minute)
+			recorded, duplicate = store.Reserve(retry)
+			Expect(duplicate).To(BeFalse(), "retry after the window should not be a duplicate")
+			Expect(recorded).To(BeIdenticalTo(retry), "retry after the window should record its own future")
+		})
+
+		It("should forget a released ID", func() {
+			store := processor.NewMemoryDedupStore(0, &manualClock{})
+
+			original := processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100})
+			store.Reserve(original)
+			store.Release(original)
+
+			retry := processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100})
+			_, duplicate := store.Reserve(retry)
+			Expect(duplicate).To(BeFalse(), "released ID should be reservable again")
+		})
+	})
+})
+
+var _ = Describe("OrderProcessor deduplication E2E", Label("e2e"), func() {
+	It("should apply concurrently retried orders only once", func() {
+		s := storage.NewStorage()
+		pool, err := worker.NewWorkerPool(4, 100)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(s, pool,
+			processor.WithHandler(processor.NewDelayedHandler(time.Millisecond)),
+			processor.WithDeduplication(time.Hour),
+		)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		numOrders := 10
+		numRetries := 20
+		futures := make([][]*processor.OrderFuture, numOrders)
+		for i := range futures {
+			futures[i] = make([]*processor.OrderFuture, numRetries)
+		}
+
+		var wg sync.WaitGroup
+		for i := range numOrders {
+			for r := range numRetries {
+				wg.Go(func() {
+					defer GinkgoRecover()
+					future, localErr := proc.SubmitAsync(context.Background(), order.Order{ID: i + 1, UserID: 1, Amount: 10})
+					Expect(localErr).NotTo(HaveOccurred(), "submitting order should not return an error")
+					futures[i][r] = future
+				})
+			}
+		}
+		wg.Wait()
+
+		proc.Shutdown()
+
+		amount, ok := s.Get(1, "USD")
+		Expect(ok).To(BeTrue(), "user 1 should exist in storage")
+		Expect(amount).To(Equal(numOrders*10), "each order should be applied exactly once")
+
+		for i := range numOrders {
+			for r := range numRetries {
+				Expect(futures[i][r]).To(BeIdenticalTo(futures[i][0]), "retries should get the original future")
+			}
+		}
+	})
+})
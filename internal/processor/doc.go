@@ -3,6 +3,54 @@
 // The processor package implements an order processing system that maintains
 // separate queues for each user to ensure orders from the same user are processed
 // sequentially while allowing parallel processing across different users.
+// A user's queue occupies a worker only while it has pending orders, so a
+// fixed-size worker pool can serve any number of distinct users. Users with
+// pending orders take turns round-robin: a worker applies at most the number of
+// orders set with WithOrdersPerTurn before it moves on to the next user.
+// Users whose pending orders include one of a higher order.Priority are served
+// first. A user's orders are applied in submission order, except that an order
+// marked Reorderable is queued ahead of the user's pending orders of lower priority.
+//
+// Each order is applied by an OrderHandler. The default handler simulates
+// processing work and credits or debits the order amount to the user's balance;
+// a custom handler can be plugged in with WithHandler.
+//
+// A transfer is queued for both the sending and the receiving user and is applied
+// once it reaches the front of both queues, so it keeps its place among the orders
+// of either user.
+//
+// The processor records the orders it applies, so that a refund is checked against
+// the amount of its original order that was applied and has not been refunded yet.
+// A refund is queued for the user of its original order and is always applied after it,
+// even if it is reorderable and has a higher priority. Only the latest applied orders are remembered, see WithRefundRetention;
+// older ones can no longer be refunded.
+//
+// A user may have an account currency set with SetAccountCurrency. Credits and debits in
+// another currency are then converted with the RateProvider configured with WithRateProvider
+// right before they are applied, and rounded to the minor unit of the account currency
+// with the RoundingMode configured with WithRounding.
+//
+// Submissions can be rate limited per user with WithRateLimit, and across all users
+// with WithGlobalRateLimit. Orders over the limit are rejected with ErrRateLimited,
+// or delayed until they are within it, depending on the RateLimitPolicy.
+//
+// Spending limits set with WithSpendingLimits cap the debits, outgoing transfers,
+// authorizations and refunds of credits of each user over rolling windows. They are
+// checked by the task that applies the user's orders one at a time, so concurrent
+// submissions cannot race past a limit.
+//
+// Orders submitted with SubmitAt wait in a timer heap in StatusScheduled and are
+// queued once they are due. Shutdown drops, flushes or persists the orders that are
+// not due yet, depending on the ScheduleShutdownPolicy set with WithScheduleShutdown.
+//
+// A Schedule created with CreateSchedule submits an order from its template at every
+// occurrence of its Recurrence. The ID of each order is derived from the schedule and the
+// time of the occurrence with OccurrenceID, so with deduplication enabled an occurrence
+// submitted twice is applied once. Schedules saved in a RecurringStore survive restarts; the
+// occurrences missed meanwhile are skipped or caught up according to the CatchUpPolicy of
+// the schedule, up to the latest 1000 of them. A processor that stops before it saves its
+// progress leaves occurrences to be submitted again by the next one, so a restart applies
+// them once only if both processors share a DedupStore set with WithDedupStore.
 //
 // Example usage:
 //
@@ -1,12 +1,173 @@
 package processor
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+)
 
 var (
 	// ErrProcessorShutdown is returned when attempting to submit orders to a shut down processor.
 	ErrProcessorShutdown = errors.New("processor is shut down")
+	// ErrSubmitCanceled is returned when the context passed to SubmitContext is done before the order is queued.
+	ErrSubmitCanceled = errors.New("submit canceled before the order was queued")
+	// ErrOrderInvalid is matched by every ValidationError returned for an order that fails validation.
+	ErrOrderInvalid = errors.New("order is invalid")
+	// ErrOrderNotFound is returned when the processor does not track an order with the given ID.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrOrderInFlight is returned when cancelling an order that a worker is already processing.
+	ErrOrderInFlight = errors.New("order is already being processed")
+	// ErrOrderFinished is returned when cancelling an order that has already been processed.
+	ErrOrderFinished = errors.New("order has already been processed")
+	// ErrOrderCancelled is the error in the outcome of an order cancelled with Cancel.
+	ErrOrderCancelled = errors.New("order was cancelled")
+	// ErrOrderPersisted is the error in the outcome of a scheduled order that was saved into the
+	// schedule store on Shutdown, to be scheduled again by the next processor using the store.
+	ErrOrderPersisted = errors.New("scheduled order was persisted")
+	// ErrScheduleExists is returned when a schedule with the ID of an existing schedule is passed to CreateSchedule.
+	ErrScheduleExists = errors.New("schedule already exists")
+	// ErrScheduleNotFound is returned when the processor has no schedule with the given ID.
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrInsufficientFunds is the error in the outcome of a debit, transfer or authorize order
+	// that would take the user's balance below their overdraft limit.
+	ErrInsufficientFunds = storage.ErrInsufficientFunds
+	// ErrHoldExists is the error in the outcome of an authorize order whose ID is already used by a hold.
+	ErrHoldExists = storage.ErrHoldExists
+	// ErrHoldNotFound is the error in the outcome of a capture or void order whose hold does not exist,
+	// has already been settled or has expired.
+	ErrHoldNotFound = storage.ErrHoldNotFound
+	// ErrHoldExceeded is the error in the outcome of a capture order for more than the held amount.
+	ErrHoldExceeded = storage.ErrHoldExceeded
+	// ErrNegativeAmount is the error in the outcome of a debit, transfer, authorize or capture order
+	// with a negative amount, if the validators configured with WithValidators let it through.
+	ErrNegativeAmount = storage.ErrNegativeValue
+	// ErrCurrencyMismatch is matched by every CurrencyMismatchError.
+	ErrCurrencyMismatch = storage.ErrCurrencyMismatch
+	// ErrOverflow is matched by every OverflowError.
+	ErrOverflow = storage.ErrOverflow
+	// ErrRefundOriginalNotFound is the error in the outcome of a refund order whose original order
+	// has not been applied, or belongs to another user.
+	ErrRefundOriginalNotFound = errors.New("refunded order not found")
+	// ErrRefundNotRefundable is the error in the outcome of a refund order whose original order
+	// is not a credit or debit of a positive amount.
+	ErrRefundNotRefundable = errors.New("order cannot be refunded")
+	// ErrRefundExceeded is the error in the outcome of a refund order for more than
+	// the part of the original order that has not been refunded yet.
+	ErrRefundExceeded = errors.New("refund exceeds the refundable amount")
+	// ErrRateNotFound is the error in the outcome of an order that cannot be converted into its user's
+	// account currency because no rate is available for the pair of currencies.
+	ErrRateNotFound = errors.New("exchange rate not found")
+	// ErrRateInvalid is returned for an exchange rate that is not a positive decimal between two ISO-4217 currencies.
+	ErrRateInvalid = errors.New("exchange rate is invalid")
+	// ErrConversionOverflow is the error in the outcome of an order whose converted amount does not fit into an int.
+	ErrConversionOverflow = errors.New("converted amount overflows")
+	// ErrLimitExceeded is matched by every LimitExceededError.
+	ErrLimitExceeded = errors.New("spending limit exceeded")
+	// ErrOverdraftLimitInvalid is returned when a negative limit is passed to SetOverdraftLimit.
+	ErrOverdraftLimitInvalid = errors.New("overdraft limit must not be negative")
 	// ErrStorageInvalid is returned when a nil storage is passed to NewOrderProcessor.
 	ErrStorageInvalid = errors.New("storage must not be nil")
 	// ErrWorkerPoolInvalid is returned when a nil worker pool is passed to NewOrderProcessor.
 	ErrWorkerPoolInvalid = errors.New("worker pool must not be nil")
+	// ErrRateLimited is returned when an order is over the rate limit of its user or of the processor
+	// and the rate limit policy rejects it.
+	ErrRateLimited = errors.New("order rate limit exceeded")
+	// ErrQueueFull is returned when the queue of the order's user is full and the overflow policy rejects the order.
+	ErrQueueFull = errors.New("user queue is full")
+	// ErrTooManyInFlight is returned when the limit of orders in flight is reached and the overflow policy rejects the order.
+	ErrTooManyInFlight = errors.New("too many orders in flight")
+	// ErrQueueCapacityInvalid is returned when a queue capacity less than or equal to 0 is passed to WithQueueCapacity.
+	ErrQueueCapacityInvalid = errors.New("queue capacity must be greater than 0")
+	// ErrHandlerInvalid is returned when a nil handler is passed to WithHandler.
+	ErrHandlerInvalid = errors.New("handler must not be nil")
+	// ErrIdleTimeoutInvalid is returned when a negative idle timeout is passed to WithIdleTimeout.
+	ErrIdleTimeoutInvalid = errors.New("idle timeout must not be negative")
+	// ErrClockInvalid is returned when a nil clock is passed to WithClock.
+	ErrClockInvalid = errors.New("clock must not be nil")
+	// ErrLoggerInvalid is returned when a nil logger is passed to WithLogger.
+	ErrLoggerInvalid = errors.New("logger must not be nil")
+	// ErrMetricsInvalid is returned when a nil metrics sink is passed to WithMetrics.
+	ErrMetricsInvalid = errors.New("metrics must not be nil")
+	// ErrMaxAmountInvalid is returned when a maximum amount less than or equal to 0 is passed to WithMaxAmount.
+	ErrMaxAmountInvalid = errors.New("max amount must be greater than 0")
+	// ErrValidatorInvalid is returned when a nil validator is passed to WithValidators.
+	ErrValidatorInvalid = errors.New("validator must not be nil")
+	// ErrDedupWindowInvalid is returned when a negative window is passed to WithDeduplication.
+	ErrDedupWindowInvalid = errors.New("deduplication window must not be negative")
+	// ErrDedupStoreInvalid is returned when a nil store is passed to WithDedupStore.
+	ErrDedupStoreInvalid = errors.New("deduplication store must not be nil")
+	// ErrRetentionInvalid is returned when a negative retention is passed to WithRetention.
+	ErrRetentionInvalid = errors.New("retention must not be negative")
+	// ErrRefundRetentionInvalid is returned when a negative retention is passed to WithRefundRetention.
+	ErrRefundRetentionInvalid = errors.New("refund retention must not be negative")
+	// ErrOverflowPolicyInvalid is returned when an unknown policy is passed to WithOverflowPolicy or OnOverflow.
+	ErrOverflowPolicyInvalid = errors.New("unknown overflow policy")
+	// ErrOverflowTimeoutInvalid is returned when a timeout less than or equal to 0 is passed to WithOverflowTimeout.
+	ErrOverflowTimeoutInvalid = errors.New("overflow timeout must be greater than 0")
+	// ErrRateLimitInvalid is returned when a rate limit that is neither zero nor has a positive rate
+	// and burst is passed to WithRateLimit, WithGlobalRateLimit or SetRateLimit.
+	ErrRateLimitInvalid = errors.New("rate limit must be zero or have a positive rate and burst")
+	// ErrRateLimitPolicyInvalid is returned when an unknown policy is passed to WithRateLimitPolicy or OnRateLimit.
+	ErrRateLimitPolicyInvalid = errors.New("unknown rate limit policy")
+	// ErrSpendingLimitInvalid is returned when a limit without a positive window and amount,
+	// or with a currency that is not an ISO-4217 code, is passed to WithSpendingLimits.
+	ErrSpendingLimitInvalid = errors.New("spending limit must have a positive window and amount")
+	// ErrOrdersPerTurnInvalid is returned when a number less than or equal to 0 is passed to WithOrdersPerTurn.
+	ErrOrdersPerTurnInvalid = errors.New("orders per turn must be greater than 0")
+	// ErrSchedulePolicyInvalid is returned when an unknown policy is passed to WithScheduleShutdown.
+	ErrSchedulePolicyInvalid = errors.New("unknown schedule shutdown policy")
+	// ErrScheduleStoreInvalid is returned when a nil store is passed to WithScheduleStore,
+	// or when SchedulePersist is set without a store.
+	ErrScheduleStoreInvalid = errors.New("schedule store must not be nil")
+	// ErrRecurringStoreInvalid is returned when a nil store is passed to WithRecurringStore.
+	ErrRecurringStoreInvalid = errors.New("recurring store must not be nil")
+	// ErrRecurrenceInvalid is returned by ParseRecurrence for an expression it cannot parse.
+	ErrRecurrenceInvalid = errors.New("recurrence is invalid")
+	// ErrScheduleInvalid is returned when a schedule without a positive ID, a valid recurrence
+	// and a known catch-up policy is passed to CreateSchedule.
+	ErrScheduleInvalid = errors.New("schedule is invalid")
+	// ErrSpillCapacityInvalid is returned when a negative capacity is passed to WithSpillCapacity.
+	ErrSpillCapacityInvalid = errors.New("spill capacity must not be negative")
+	// ErrDefaultCurrencyInvalid is returned when a code that is not an ISO-4217 code is passed to WithDefaultCurrency.
+	ErrDefaultCurrencyInvalid = errors.New("default currency must be an ISO-4217 code")
+	// ErrAccountCurrencyInvalid is returned when a non-empty code that is not an ISO-4217 code is passed to SetAccountCurrency.
+	ErrAccountCurrencyInvalid = errors.New("account currency must be an ISO-4217 code")
+	// ErrRateProviderInvalid is returned when a nil rate provider is passed to WithRateProvider.
+	ErrRateProviderInvalid = errors.New("rate provider must not be nil")
+	// ErrRoundingModeInvalid is returned when an unknown mode is passed to WithRounding.
+	ErrRoundingModeInvalid = errors.New("unknown rounding mode")
+	// ErrHoldTTLInvalid is returned when a negative TTL is passed to WithHoldTTL.
+	ErrHoldTTLInvalid = errors.New("hold TTL must not be negative")
+	// ErrMaxInFlightInvalid is returned when a limit less than or equal to 0 is passed to WithMaxInFlight.
+	ErrMaxInFlightInvalid = errors.New("max in-flight orders must be greater than 0")
 )
+
+// CurrencyMismatchError is the error in the outcome of an order that refers to an amount
+// in another currency, such as a capture of a hold or a refund of an order placed in another currency.
+// It matches ErrCurrencyMismatch.
+type CurrencyMismatchError = storage.CurrencyMismatchError
+
+// OverflowError is the error in the outcome of an order that would take a balance
+// beyond the range of an int. The balance is left unchanged. It matches ErrOverflow.
+type OverflowError = storage.OverflowError
+
+// LimitExceededError is the error in the outcome of a debit or transfer that would take
+// the debits of its user beyond a spending limit set with WithSpendingLimits.
+// It matches ErrLimitExceeded.
+type LimitExceededError struct {
+	// Limit is the spending limit that would be exceeded.
+	Limit SpendingLimit
+	// Remaining is how much the user may still debit within the window of the limit.
+	Remaining int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("spending limit exceeded: %d %s remaining of %d %s per %s",
+		e.Remaining, e.Limit.Currency, e.Limit.Amount, e.Limit.Currency, e.Limit.Window)
+}
+
+// Is reports whether target is ErrLimitExceeded, so that errors.Is(err, ErrLimitExceeded)
+// holds for every exceeded limit.
+func (e *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"context"
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"sync"
+	"time"
+)
+
+// Outcome describes how a submitted order was processed.
+type Outcome struct {
+	// Order is the submitted order.
+	Order order.Order
+	// Status is the final state of the order.
+	Status Status
+	// Balance is the user's balance right after the order was applied.
+	Balance int
+	// Conversion describes how the order was converted into the account currency of its user,
+	// or is nil if it was applied in its own currency.
+	Conversion *Conversion
+	// Err is the reason the order failed, or nil if it was applied.
+	Err error
+	// SubmittedAt is the time the order was submitted.
+	SubmittedAt time.Time
+	// StartedAt is the time a worker started processing the order.
+	StartedAt time.Time
+	// FinishedAt is the time the outcome became known.
+	FinishedAt time.Time
+}
+
+// OrderFuture is a handle to the outcome of a submitted order.
+// It is resolved exactly once, after which its outcome never changes.
+type OrderFuture struct {
+	order   order.Order
+	clock   Clock
+	observe func(*OrderFuture, Outcome)
+	done    chan struct{}
+	outcome Outcome
+
+	mu        sync.Mutex
+	status    Status
+	history   []StatusChange
+	arrived   int
+	parked    UserQueue
+	withdrawn bool
+	converted *Conversion
+}
+
+// NewOrderFuture creates an unresolved OrderFuture for the given order.
+func NewOrderFuture(ord order.Order) *OrderFuture {
+	return newOrderFuture(ord, systemClock{}, nil)
+}
+
+// newOrderFuture creates an unresolved OrderFuture that takes its timestamps from clock
+// and reports its outcome to observe, if set, right before waking up waiters.
+func newOrderFuture(ord order.Order, clock Clock, observe func(*OrderFuture, Outcome)) *OrderFuture {
+	return &OrderFuture{
+		order:   ord,
+		clock:   clock,
+		observe: observe,
+		done:    make(chan struct{}),
+		status:  StatusReceived,
+		history: []StatusChange{{Status: StatusReceived, At: clock.Now()}},
+	}
+}
+
+// Order returns the submitted order.
+func (f *OrderFuture) Order() order.Order {
+	return f.order
+}
+
+// Done returns a channel that is closed once the outcome of the order is known.
+func (f *OrderFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the outcome of the order is known or ctx is done.
+// Returns ctx.Err() if ctx is done first.
+func (f *OrderFuture) Wait(ctx context.Context) (Outcome, error) {
+	select {
+	case <-f.done:
+		return f.outcome, nil
+	case <-ctx.Done():
+		return Outcome{}, ctx.Err()
+	}
+}
+
+// Status returns a snapshot of the lifecycle of the order.
+func (f *OrderFuture) Status() OrderStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	status := OrderStatus{
+		Order:   f.order,
+		Status:  f.status,
+		History: make([]StatusChange, len(f.history)),
+	}
+	copy(status.History, f.history)
+
+	if f.status.Final() {
+		status.Err = f.outcome.Err
+	}
+	return status
+}
+
+// queue records that the order has been added to its user's queue.
+func (f *OrderFuture) queue() {
+	f.transition(StatusQueued)
+}
+
+// start records that a worker picked up the order.
+func (f *OrderFuture) start() {
+	f.transition(StatusProcessing)
+}
+
+// convert records how the order was converted before it is applied.
+func (f *OrderFuture) convert(conversion *Conversion) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.converted = conversion
+}
+
+// transition moves the order to a non-final status.
+// It has no effect once the future is resolved.
+func (f *OrderFuture) transition(status Status) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.status.Final() {
+		return
+	}
+
+	f.status = status
+	f.history = append(f.history, StatusChange{Status: status, At: f.clock.Now()})
+}
+
+// resolve records the final status and outcome of the order, reports it to the observer
+// and wakes up all waiters. Returns false if the future has already been resolved.
+func (f *OrderFuture) resolve(status Status, balance int, err error) bool {
+	return f.finish(status, balance, err, true)
+}
+
+// reject resolves the future of an order that could not be queued with StatusRejected and err.
+// Unlike resolve, it does not report the outcome to the observer.
+// Returns false if the future has already been resolved.
+func (f *OrderFuture) reject(err error) bool {
+	return f.finish(StatusRejected, 0, err, false)
+}
+
+func (f *OrderFuture) finish(status Status, balance int, err error, notify bool) bool {
+	f.mu.Lock()
+	if f.status.Final() {
+		f.mu.Unlock()
+		return false
+	}
+
+	now := f.clock.Now()
+	f.status = status
+	f.history = append(f.history, StatusChange{Status: status, At: now})
+	f.outcome = Outcome{
+		Order:       f.order,
+		Status:      status,
+		Balance:     balance,
+		Conversion:  f.converted,
+		Err:         err,
+		SubmittedAt: f.history[0].At,
+		StartedAt:   f.startedAt(),
+		FinishedAt:  now,
+	}
+	f.mu.Unlock()
+
+	if notify && f.observe != nil {
+		f.observe(f, f.outcome)
+	}
+	close(f.done)
+	return true
+}
+
+// startedAt returns the time the order entered StatusProcessing, or the zero time.
+// It must be called with mu held.
+func (f *OrderFuture) startedAt() time.Time {
+	for _, change := range f.history {
+		if change.Status == StatusProcessing {
+			return change.At
+		}
+	}
+	return time.Time{}
+}
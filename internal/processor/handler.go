@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"context"
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"time"
+)
+
+// defaultProcessingDelay is the simulated processing time of the default handler.
+const defaultProcessingDelay = 200 * time.Millisecond
+
+// OrderHandler applies a single order to storage.
+// It is called sequentially for the orders of one user, and concurrently
+// for the orders of different users. Refund orders never reach the handler;
+// it is passed the credit or debit that reverses the original order instead.
+type OrderHandler interface {
+	// Handle applies the order and returns the user's balance right after it.
+	// A non-nil error marks the order as failed and is reported in its outcome.
+	Handle(ctx context.Context, ord order.Order, storage storage.Storage) (int, error)
+}
+
+// OrderHandlerFunc adapts an ordinary function to the OrderHandler interface.
+type OrderHandlerFunc func(ctx context.Context, ord order.Order, storage storage.Storage) (int, error)
+
+// Handle calls f(ctx, ord, storage).
+func (f OrderHandlerFunc) Handle(ctx context.Context, ord order.Order, storage storage.Storage) (int, error) {
+	return f(ctx, ord, storage)
+}
+
+type delayedHandler struct {
+	delay time.Duration
+}
+
+// NewDelayedHandler creates an OrderHandler that waits for the given delay, simulating
+// processing work, and then applies the order to storage according to its kind.
+// A debit, transfer or authorization that would exceed the user's overdraft limit
+// fails with ErrInsufficientFunds, and an order that would take a balance beyond
+// the range of an int fails with an *OverflowError.
+// It is the default handler of an OrderProcessor, with a delay of 200ms.
+func NewDelayedHandler(delay time.Duration) OrderHandler {
+	return &delayedHandler{
+		delay: delay,
+	}
+}
+
+func (h *delayedHandler) Handle(ctx context.Context, ord order.Order, storage storage.Storage) (int, error) {
+	if h.delay > 0 {
+		timer := time.NewTimer(h.delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	return apply(ord, storage)
+}
+
+// apply applies the order to storage according to its kind
+// and returns the resulting available balance of the order's user in the order's currency.
+func apply(ord order.Order, storage storage.Storage) (int, error) {
+	switch ord.Kind {
+	case order.KindDebit:
+		return storage.Debit(ord.UserID, ord.Currency, ord.Amount)
+	case order.KindTransfer:
+		return storage.Transfer(ord.UserID, ord.To, ord.Currency, ord.Amount)
+	case order.KindAuthorize:
+		return storage.Hold(ord.UserID, ord.Currency, ord.ID, ord.Amount)
+	case order.KindCapture:
+		return storage.Capture(ord.UserID, ord.Currency, ord.HoldID, ord.Amount)
+	case order.KindVoid:
+		return storage.Release(ord.UserID, ord.Currency, ord.HoldID)
+	default:
+		return storage.Add(ord.UserID, ord.Currency, ord.Amount)
+	}
+}
@@ -0,0 +1,117 @@
+package processor_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
+)
+
+var _ = Describe("OrderHandler", Label("unit"), func() {
+	When("handling an order with the delayed handler", func() {
+		It("should add the order amount to the user's balance", func() {
+			o := order.Order{ID: 1, UserID: 1, Amount: 100, Currency: "USD"}
+			s := storage.NewStorage()
+			s.Add(o.UserID, "USD", 50)
+
+			handler := processor.NewDelayedHandler(time.Millisecond)
+			balance, err := handler.Handle(context.Background(), o, s)
+
+			Expect(err).NotTo(HaveOccurred(), "handling an order should not return an error")
+			Expect(balance).To(Equal(150), "handler should return the resulting balance")
+		})
+
+		It("should not apply the order when the context is done before the delay passes", func() {
+			o := order.Order{ID: 1, UserID: 1, Amount: 100, Currency: "USD"}
+			s := storage.NewStorage()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			handler := processor.NewDelayedHandler(time.Hour)
+			_, err := handler.Handle(ctx, o, s)
+
+			Expect(err).To(MatchError(context.Canceled), "handler should return the context error")
+			_, ok := s.Get(o.UserID, "USD")
+			Expect(ok).To(BeFalse(), "order should not be applied")
+		})
+	})
+
+	When("handling a debit order", func() {
+		It("should subtract the order amount from the user's balance", func() {
+			o := order.Order{ID: 1, UserID: 1, Kind: order.KindDebit, Amount: 30, Currency: "USD"}
+			s := storage.NewStorage()
+			s.Add(o.UserID, "USD", 50)
+
+			balance, err := processor.NewDelayedHandler(0).Handle(context.Background(), o, s)
+
+			Expect(err).NotTo(HaveOccurred(), "debiting within the balance should not return an error")
+			Expect(balance).To(Equal(20), "handler should return the resulting balance")
+		})
+
+		It("should fail with ErrInsufficientFunds and keep the balance when funds are insufficient", func() {
+			o := order.Order{ID: 1, UserID: 1, Kind: order.KindDebit, Amount: 80, Currency: "USD"}
+			s := storage.NewStorage()
+			s.Add(o.UserID, "USD", 50)
+
+			balance, err := processor.NewDelayedHandler(0).Handle(context.Background(), o, s)
+
+			Expect(err).To(MatchError(processor.ErrInsufficientFunds), "debiting beyond the balance should fail")
+			Expect(balance).To(Equal(50), "handler should return the unchanged balance")
+		})
+	})
+
+	When("handling an order that would overflow the balance", func() {
+		It("should fail with an OverflowError and keep the balance", func() {
+			o := order.Order{ID: 1, UserID: 1, Amount: 1, Currency: "USD"}
+			s := storage.NewStorage()
+			s.Add(o.UserID, "USD", math.MaxInt)
+
+			balance, err := processor.NewDelayedHandler(0).Handle(context.Background(), o, s)
+
+			Expect(err).To(MatchError(processor.ErrOverflow), "crediting beyond the range of an int should fail")
+			Expect(balance).To(Equal(math.MaxInt), "handler should return the unchanged balance")
+		})
+	})
+})
+
+var _ = Describe("OrderHandler E2E", Label("e2e"), func() {
+	It("should report an overflowing order in its outcome and leave the balance untouched", func() {
+		pool, err := worker.NewWorkerPool(1, 10)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(storage.NewStorage(), pool, processor.WithHandler(processor.NewDelayedHandler(0)))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+		DeferCleanup(proc.Shutdown)
+
+		results := proc.SubmitBatch(context.Background(), []order.Order{
+			{ID: 1, UserID: 1, Amount: math.MaxInt},
+			{ID: 2, UserID: 1, Amount: 1},
+		})
+		var outcomes []processor.Outcome
+		for _, result := range results {
+			Expect(result.Err).NotTo(HaveOccurred(), "every order should be accepted")
+			outcome, err := result.Future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+			outcomes = append(outcomes, outcome)
+		}
+
+		Expect(outcomes[0].Status).To(Equal(processor.StatusApplied), "the order within range should be applied")
+		Expect(outcomes[1].Status).To(Equal(processor.StatusFailed), "the overflowing order should fail")
+		Expect(outcomes[1].Err).To(MatchError(processor.ErrOverflow), "the outcome should carry ErrOverflow")
+
+		var overflow *processor.OverflowError
+		Expect(errors.As(outcomes[1].Err, &overflow)).To(BeTrue(), "the outcome should carry an OverflowError")
+		Expect(overflow.Value).To(Equal(math.MaxInt), "the error should report the balance it started from")
+
+		balance, _ := proc.GetBalance(1, "")
+		Expect(balance).To(Equal(math.MaxInt), "the balance should be left untouched")
+	})
+})
@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHoldTTL is how long a hold may stay uncaptured before it is released.
+	defaultHoldTTL = 15 * time.Minute
+	// maxHoldSweepInterval is the longest time between two checks for expired holds.
+	maxHoldSweepInterval = time.Second
+)
+
+// heldAmount identifies a hold placed by an applied authorize order and the time it expires.
+type heldAmount struct {
+	userID    int
+	currency  string
+	holdID    int
+	expiresAt time.Time
+}
+
+// holdTracker remembers the holds placed by applied authorize orders until
+// they are captured, voided or expire.
+type holdTracker struct {
+	mu    sync.Mutex
+	holds map[int]heldAmount
+}
+
+func newHoldTracker() *holdTracker {
+	return &holdTracker{
+		holds: make(map[int]heldAmount),
+	}
+}
+
+// observe starts tracking the hold placed by an applied authorize order,
+// and stops tracking the hold settled by an applied capture or void order.
+func (t *holdTracker) observe(outcome Outcome, ttl time.Duration) {
+	if outcome.Status != StatusApplied {
+		return
+	}
+
+	ord := outcome.Order
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch ord.Kind {
+	case order.KindAuthorize:
+		t.holds[ord.ID] = heldAmount{userID: ord.UserID, currency: ord.Currency, holdID: ord.ID, expiresAt: outcome.FinishedAt.Add(ttl)}
+	case order.KindCapture, order.KindVoid:
+		delete(t.holds, ord.HoldID)
+	}
+}
+
+// expired stops tracking and returns the holds that expire at or before now.
+func (t *holdTracker) expired(now time.Time) []heldAmount {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var expired []heldAmount
+	for holdID, hold := range t.holds {
+		if !hold.expiresAt.After(now) {
+			expired = append(expired, hold)
+			delete(t.holds, holdID)
+		}
+	}
+	return expired
+}
+
+// releaseExpiredHolds periodically returns the expired holds to the available balance of their users.
+// A hold that is captured or voided concurrently is left to that order.
+func (o *orderProcessor) releaseExpiredHolds() {
+	ticker := time.NewTicker(min(o.holdTTL, maxHoldSweepInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.shutdownChan:
+			return
+		case <-ticker.C:
+			for _, hold := range o.holds.expired(o.clock.Now()) {
+				if _, err := o.storage.Release(hold.userID, hold.currency, hold.holdID); err == nil {
+					o.logger.Debug("released expired hold",
+						slog.Int("hold_id", hold.holdID),
+						slog.Int("user_id", hold.userID),
+					)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package processor_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+)
+
+var _ = Describe("Holds", Label("e2e"), func() {
+	var (
+		s    storage.Storage
+		proc processor.OrderProcessor
+	)
+
+	start := func(opts ...processor.Option) {
+		s = storage.NewStorage()
+		s.Add(1, "USD", 100)
+
+		proc = newTestProcessor(s, opts...)
+	}
+
+	It("should reserve the authorized amount and spend only the captured part of it", func() {
+		start()
+
+		outcome := apply(proc, order.Order{ID: 1, UserID: 1, Kind: order.KindAuthorize, Amount: 80})
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "authorizing within the balance should succeed")
+		Expect(outcome.Balance).To(Equal(20), "the outcome should report the available balance")
+
+		balance, ok := proc.GetBalanceDetails(1, "USD")
+		Expect(ok).To(BeTrue(), "the user should exist")
+		Expect(balance).To(Equal(storage.Balance{Available: 20, Held: 80}), "the authorized amount should be held")
+
+		outcome = apply(proc, order.Order{ID: 2, UserID: 1, Kind: order.KindCapture, HoldID: 1, Amount: 50})
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "capturing part of the hold should succeed")
+
+		balance, _ = proc.GetBalanceDetails(1, "USD")
+		Expect(balance).To(Equal(storage.Balance{Available: 50}), "the rest of the hold should become available")
+	})
+
+	It("should return the whole hold on void", func() {
+		start()
+
+		apply(proc, order.Order{ID: 1, UserID: 1, Kind: order.KindAuthorize, Amount: 80})
+		outcome := apply(proc, order.Order{ID: 2, UserID: 1, Kind: order.KindVoid, HoldID: 1})
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "voiding a hold should succeed")
+
+		balance, _ := proc.GetBalanceDetails(1, "USD")
+		Expect(balance).To(Equal(storage.Balance{Available: 100}), "the whole hold should become available")
+
+		outcome = apply(proc, order.Order{ID: 3, UserID: 1, Kind: order.KindCapture, HoldID: 1})
+		Expect(outcome.Err).To(MatchError(processor.ErrHoldNotFound), "a voided hold should not be capturable")
+	})
+
+	It("should fail captures beyond the held amount and authorizations beyond the balance", func() {
+		start()
+
+		outcome := apply(proc, order.Order{ID: 1, UserID: 1, Kind: order.KindAuthorize, Amount: 101})
+		Expect(outcome.Err).To(MatchError(processor.ErrInsufficientFunds), "authorizing beyond the balance should fail")
+
+		apply(proc, order.Order{ID: 2, UserID: 1, Kind: order.KindAuthorize, Amount: 50})
+		outcome = apply(proc, order.Order{ID: 3, UserID: 1, Kind: order.KindCapture, HoldID: 2, Amount: 51})
+		Expect(outcome.Err).To(MatchError(processor.ErrHoldExceeded), "capturing beyond the hold should fail")
+
+		balance, _ := proc.GetBalanceDetails(1, "USD")
+		Expect(balance).To(Equal(storage.Balance{Available: 50, Held: 50}), "failed orders should not change the balance")
+	})
+
+	It("should release a hold that is not captured within the TTL", func() {
+		start(processor.WithHoldTTL(20 * time.Millisecond))
+
+		apply(proc, order.Order{ID: 1, UserID: 1, Kind: order.KindAuthorize, Amount: 80})
+
+		Eventually(func() storage.Balance {
+			balance, _ := proc.GetBalanceDetails(1, "USD")
+			return balance
+		}).WithTimeout(time.Second).Should(Equal(storage.Balance{Available: 100}), "the expired hold should become available")
+
+		outcome := apply(proc, order.Order{ID: 2, UserID: 1, Kind: order.KindCapture, HoldID: 1})
+		Expect(outcome.Err).To(MatchError(processor.ErrHoldNotFound), "an expired hold should not be capturable")
+	})
+})
@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"context"
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"sync"
+)
+
+// ledgerEntry is an applied order and the part of its amount that has been refunded.
+type ledgerEntry struct {
+	order    order.Order
+	refunded int
+}
+
+// defaultRefundRetention is the default number of applied orders the processor remembers for refunds.
+const defaultRefundRetention = 100000
+
+// orderLedger records the orders applied by the processor, so that refunds can be checked
+// against what was actually applied and already refunded. Orders with a zero ID are not recorded.
+// If several applied orders share an ID, the latest one is recorded. Orders are forgotten
+// once more than retention orders have been recorded after them.
+type orderLedger struct {
+	mu        sync.Mutex
+	entries   map[int]*ledgerEntry
+	recorded  []*ledgerEntry
+	retention int
+}
+
+func newOrderLedger(retention int) *orderLedger {
+	return &orderLedger{
+		entries:   make(map[int]*ledgerEntry),
+		retention: retention,
+	}
+}
+
+// record remembers an applied order and forgets the oldest orders beyond the retention limit.
+func (l *orderLedger) record(ord order.Order) {
+	if ord.ID == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := &ledgerEntry{order: ord}
+	l.entries[ord.ID] = entry
+	l.recorded = append(l.recorded, entry)
+	for len(l.recorded) > l.retention {
+		oldest := l.recorded[0]
+		if l.entries[oldest.order.ID] == oldest {
+			delete(l.entries, oldest.order.ID)
+		}
+		l.recorded[0] = nil
+		l.recorded = l.recorded[1:]
+	}
+}
+
+// reversal returns the order that reverses the amount of the refund order, or ErrRefundOriginalNotFound,
+// ErrRefundNotRefundable, ErrRefundExceeded, or a *CurrencyMismatchError if the refund is in
+// another currency than the original order.
+func (l *orderLedger) reversal(refund order.Order) (order.Order, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[refund.OriginalOrderID]
+	if !ok || entry.order.UserID != refund.UserID {
+		return order.Order{}, ErrRefundOriginalNotFound
+	}
+
+	original := entry.order
+	if original.Currency != refund.Currency {
+		return order.Order{}, &CurrencyMismatchError{Expected: original.Currency, Actual: refund.Currency}
+	}
+	if (original.Kind != order.KindCredit && original.Kind != order.KindDebit) || original.Amount <= 0 {
+		return order.Order{}, ErrRefundNotRefundable
+	}
+
+	remaining := original.Amount - entry.refunded
+	amount := refund.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount == 0 || amount > remaining {
+		return order.Order{}, ErrRefundExceeded
+	}
+
+	kind := order.KindDebit
+	if original.Kind == order.KindDebit {
+		kind = order.KindCredit
+	}
+	return order.Order{ID: refund.ID, UserID: refund.UserID, Kind: kind, Amount: amount, Currency: refund.Currency}, nil
+}
+
+// refund records that amount of the original order has been refunded.
+func (l *orderLedger) refund(originalID, amount int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry, ok := l.entries[originalID]; ok {
+		entry.refunded += amount
+	}
+}
+
+// ledgerHandler records the orders applied by the next handler in the ledger and
+// turns refund orders into the credit or debit that reverses the original order.
+// Since handlers are called sequentially for the orders of one user and a refund belongs
+// to the user of its original order, checking and applying a refund cannot interleave
+// with another refund of the same order.
+type ledgerHandler struct {
+	next   OrderHandler
+	ledger *orderLedger
+}
+
+func (h *ledgerHandler) Handle(ctx context.Context, ord order.Order, storage storage.Storage) (int, error) {
+	if ord.Kind != order.KindRefund {
+		balance, err := h.next.Handle(ctx, ord, storage)
+		if err == nil {
+			h.ledger.record(ord)
+		}
+		return balance, err
+	}
+
+	reversal, err := h.ledger.reversal(ord)
+	if err != nil {
+		balance, _ := storage.Get(ord.UserID, ord.Currency)
+		return balance, err
+	}
+
+	balance, err := h.next.Handle(ctx, reversal, storage)
+	if err == nil {
+		h.ledger.refund(ord.OriginalOrderID, reversal.Amount)
+	}
+	return balance, err
+}
@@ -0,0 +1,22 @@
+package processor
+
+import "github.com/antoniuk-oleksandr/order_processor/internal/order"
+
+// Metrics receives events about orders and user queues of an OrderProcessor.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// OrderQueued is called when an order has been added to its user's queue.
+	OrderQueued(ord order.Order)
+	// OrderProcessed is called with the final outcome of every queued order.
+	OrderProcessed(outcome Outcome)
+	// QueueEvicted is called when the idle queue of a user has been evicted.
+	QueueEvicted(userID int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) OrderQueued(order.Order) {}
+
+func (noopMetrics) OrderProcessed(Outcome) {}
+
+func (noopMetrics) QueueEvicted(int) {}
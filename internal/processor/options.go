@@ -0,0 +1,420 @@
+package processor
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Option configures an OrderProcessor created by NewOrderProcessor.
+type Option func(*orderProcessor) error
+
+// WithQueueCapacity sets the maximum number of pending orders per user.
+// Returns ErrQueueCapacityInvalid if capacity <= 0.
+func WithQueueCapacity(capacity int) Option {
+	return func(o *orderProcessor) error {
+		if capacity <= 0 {
+			return ErrQueueCapacityInvalid
+		}
+
+		o.queueCapacity = capacity
+		return nil
+	}
+}
+
+// WithHandler sets the OrderHandler that applies each order.
+// Use NewDelayedHandler to keep the default behaviour with a different processing delay.
+// Returns ErrHandlerInvalid if handler is nil.
+func WithHandler(handler OrderHandler) Option {
+	return func(o *orderProcessor) error {
+		if handler == nil {
+			return ErrHandlerInvalid
+		}
+
+		o.handler = handler
+		return nil
+	}
+}
+
+// WithClock sets the Clock used for order timestamps and idle queue eviction.
+// Returns ErrClockInvalid if clock is nil.
+func WithClock(clock Clock) Option {
+	return func(o *orderProcessor) error {
+		if clock == nil {
+			return ErrClockInvalid
+		}
+
+		o.clock = clock
+		return nil
+	}
+}
+
+// WithLogger sets the logger that reports failed orders and evicted queues.
+// By default nothing is logged. Returns ErrLoggerInvalid if logger is nil.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *orderProcessor) error {
+		if logger == nil {
+			return ErrLoggerInvalid
+		}
+
+		o.logger = logger
+		return nil
+	}
+}
+
+// WithMetrics sets the Metrics sink that receives order and queue events.
+// Returns ErrMetricsInvalid if metrics is nil.
+func WithMetrics(metrics Metrics) Option {
+	return func(o *orderProcessor) error {
+		if metrics == nil {
+			return ErrMetricsInvalid
+		}
+
+		o.metrics = metrics
+		return nil
+	}
+}
+
+// WithIdleTimeout sets how long a user's queue may stay without pending orders
+// before it is evicted. An evicted queue is recreated on the next Submit for that user.
+// A zero timeout disables eviction. Returns ErrIdleTimeoutInvalid if timeout is negative.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *orderProcessor) error {
+		if timeout < 0 {
+			return ErrIdleTimeoutInvalid
+		}
+
+		o.idleTimeout = timeout
+		return nil
+	}
+}
+
+// WithDefaultCurrency sets the currency of orders submitted without one,
+// and of balance lookups without one. The default is "USD".
+// Returns ErrDefaultCurrencyInvalid if currency is not an ISO-4217 code.
+func WithDefaultCurrency(currency string) Option {
+	return func(o *orderProcessor) error {
+		if !isCurrencyCode(currency) {
+			return ErrDefaultCurrencyInvalid
+		}
+
+		o.defaultCurrency = currency
+		return nil
+	}
+}
+
+// WithRateProvider sets the provider of the exchange rates used to convert credits and debits
+// into the account currency of their user, set with SetAccountCurrency.
+// Returns ErrRateProviderInvalid if provider is nil.
+func WithRateProvider(provider RateProvider) Option {
+	return func(o *orderProcessor) error {
+		if provider == nil {
+			return ErrRateProviderInvalid
+		}
+
+		o.converter.provider = provider
+		return nil
+	}
+}
+
+// WithRounding sets how converted amounts are rounded to the minor unit of the account currency.
+// The default is RoundHalfEven. Returns ErrRoundingModeInvalid if mode is not one of the defined modes.
+func WithRounding(mode RoundingMode) Option {
+	return func(o *orderProcessor) error {
+		if !mode.valid() {
+			return ErrRoundingModeInvalid
+		}
+
+		o.converter.rounding = mode
+		return nil
+	}
+}
+
+// WithHoldTTL sets how long a hold placed by an authorize order may stay uncaptured
+// before it is released back to the user's available balance. The default is 15 minutes.
+// A zero TTL disables expiry. Returns ErrHoldTTLInvalid if ttl is negative.
+func WithHoldTTL(ttl time.Duration) Option {
+	return func(o *orderProcessor) error {
+		if ttl < 0 {
+			return ErrHoldTTLInvalid
+		}
+
+		o.holdTTL = ttl
+		return nil
+	}
+}
+
+// WithOverflowPolicy sets what Submit does when there is no room for an order.
+// It can be overridden for a single submit with OnOverflow.
+// Returns ErrOverflowPolicyInvalid if policy is not one of the defined policies.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *orderProcessor) error {
+		if !policy.valid() {
+			return ErrOverflowPolicyInvalid
+		}
+
+		o.overflowPolicy = policy
+		return nil
+	}
+}
+
+// WithOverflowTimeout sets how long OverflowBlockTimeout waits for room.
+// Returns ErrOverflowTimeoutInvalid if timeout <= 0.
+func WithOverflowTimeout(timeout time.Duration) Option {
+	return func(o *orderProcessor) error {
+		if timeout <= 0 {
+			return ErrOverflowTimeoutInvalid
+		}
+
+		o.overflowTimeout = timeout
+		return nil
+	}
+}
+
+// WithSpillCapacity sets how many orders OverflowSpill accepts beyond a full user queue.
+// Returns ErrSpillCapacityInvalid if capacity < 0.
+func WithSpillCapacity(capacity int) Option {
+	return func(o *orderProcessor) error {
+		if capacity < 0 {
+			return ErrSpillCapacityInvalid
+		}
+
+		o.spillCapacity = capacity
+		return nil
+	}
+}
+
+// WithMaxInFlight limits the total number of queued and processing orders across all users.
+// Submit handles a reached limit according to the overflow policy.
+// Returns ErrMaxInFlightInvalid if limit <= 0.
+func WithMaxInFlight(limit int) Option {
+	return func(o *orderProcessor) error {
+		if limit <= 0 {
+			return ErrMaxInFlightInvalid
+		}
+
+		o.inFlight = make(chan struct{}, limit)
+		return nil
+	}
+}
+
+// WithRateLimit limits how fast orders of every user can be submitted, with one token bucket
+// per user. It can be overridden for a single user at runtime with SetRateLimit.
+// By default the rate is not limited.
+// Returns ErrRateLimitInvalid if limit is neither zero nor has a positive rate and burst.
+func WithRateLimit(limit RateLimit) Option {
+	return func(o *orderProcessor) error {
+		if !limit.valid() {
+			return ErrRateLimitInvalid
+		}
+
+		o.limiter.user = limit
+		return nil
+	}
+}
+
+// WithGlobalRateLimit limits how fast orders can be submitted across all users,
+// on top of the per-user limits. By default the rate is not limited.
+// Returns ErrRateLimitInvalid if limit is neither zero nor has a positive rate and burst.
+func WithGlobalRateLimit(limit RateLimit) Option {
+	return func(o *orderProcessor) error {
+		if !limit.valid() {
+			return ErrRateLimitInvalid
+		}
+
+		o.limiter.global = limit
+		return nil
+	}
+}
+
+// WithRateLimitPolicy sets what Submit does with an order over the rate limit.
+// The default is RateLimitReject. It can be overridden for a single submit with OnRateLimit.
+// Returns ErrRateLimitPolicyInvalid if policy is not one of the defined policies.
+func WithRateLimitPolicy(policy RateLimitPolicy) Option {
+	return func(o *orderProcessor) error {
+		if !policy.valid() {
+			return ErrRateLimitPolicyInvalid
+		}
+
+		o.rateLimitPolicy = policy
+		return nil
+	}
+}
+
+// WithSpendingLimits caps the debits, outgoing transfers and authorizations of every user over rolling windows,
+// e.g. a daily and a monthly limit. Orders that would exceed a limit fail with a
+// *LimitExceededError stating the remaining allowance. Limits are checked right before
+// an order is applied, after it is converted into the account currency of its user;
+// a refund of a credit is checked as the debit that reverses it.
+// Returns ErrSpendingLimitInvalid if a limit has no positive window and amount,
+// or its currency is not an ISO-4217 code.
+func WithSpendingLimits(limits ...SpendingLimit) Option {
+	return func(o *orderProcessor) error {
+		for _, limit := range limits {
+			if !limit.valid() {
+				return ErrSpendingLimitInvalid
+			}
+		}
+
+		o.spendingLimits = append(o.spendingLimits, limits...)
+		return nil
+	}
+}
+
+// WithOrdersPerTurn sets how many orders of a user a worker applies before it moves on
+// to the next user with pending orders, so that users with a long backlog do not starve
+// the others. Users with pending orders take turns round-robin. The default is 16.
+// Returns ErrOrdersPerTurnInvalid if n <= 0.
+func WithOrdersPerTurn(n int) Option {
+	return func(o *orderProcessor) error {
+		if n <= 0 {
+			return ErrOrdersPerTurnInvalid
+		}
+
+		o.ordersPerTurn = n
+		return nil
+	}
+}
+
+// WithScheduleShutdown sets what Shutdown does with orders submitted with SubmitAt that are
+// not due yet. The default is ScheduleDrop. SchedulePersist requires a store set with WithScheduleStore.
+// Returns ErrSchedulePolicyInvalid if policy is not one of the defined policies.
+func WithScheduleShutdown(policy ScheduleShutdownPolicy) Option {
+	return func(o *orderProcessor) error {
+		if !policy.valid() {
+			return ErrSchedulePolicyInvalid
+		}
+
+		o.schedulePolicy = policy
+		return nil
+	}
+}
+
+// WithScheduleStore sets the ScheduleStore that SchedulePersist saves the scheduled orders into.
+// NewOrderProcessor schedules the orders saved in the store again.
+// Returns ErrScheduleStoreInvalid if store is nil.
+func WithScheduleStore(store ScheduleStore) Option {
+	return func(o *orderProcessor) error {
+		if store == nil {
+			return ErrScheduleStoreInvalid
+		}
+
+		o.scheduleStore = store
+		return nil
+	}
+}
+
+// WithRecurringStore sets the RecurringStore the schedules created with CreateSchedule are saved into.
+// NewOrderProcessor creates the schedules saved in the store again and catches up the occurrences
+// missed while no processor ran them, according to the CatchUpPolicy of each schedule.
+// Occurrences submitted after the store was last saved are submitted again, so the processors
+// should share a DedupStore, see WithDedupStore, to apply each of them once.
+// Returns ErrRecurringStoreInvalid if store is nil.
+func WithRecurringStore(store RecurringStore) Option {
+	return func(o *orderProcessor) error {
+		if store == nil {
+			return ErrRecurringStoreInvalid
+		}
+
+		o.recurringStore = store
+		return nil
+	}
+}
+
+// WithDrainOnShutdown sets whether Shutdown processes the orders still pending in user queues.
+// When disabled, pending orders that no worker has started are failed with ErrProcessorShutdown,
+// and the context passed to the handler and the rate provider is cancelled.
+// Draining is enabled by default.
+func WithDrainOnShutdown(drain bool) Option {
+	return func(o *orderProcessor) error {
+		o.drainOnShutdown = drain
+		return nil
+	}
+}
+
+// WithMaxAmount rejects orders whose absolute amount exceeds limit.
+// Returns ErrMaxAmountInvalid if limit <= 0.
+func WithMaxAmount(limit int) Option {
+	return func(o *orderProcessor) error {
+		if limit <= 0 {
+			return ErrMaxAmountInvalid
+		}
+
+		o.validators = append(o.validators, MaxAmount(limit))
+		return nil
+	}
+}
+
+// WithValidators adds validators that every order must pass before it is queued.
+// They run after the built-in PositiveUserID, NonZeroAmount, KnownKind, KnownPriority, PositiveDebit,
+// PositiveCredit, TransferRecipient, HoldReference, RefundReference and CurrencyCode rules.
+// Returns ErrValidatorInvalid if any validator is nil.
+func WithValidators(validators ...Validator) Option {
+	return func(o *orderProcessor) error {
+		for _, v := range validators {
+			if v == nil {
+				return ErrValidatorInvalid
+			}
+		}
+
+		o.validators = append(o.validators, validators...)
+		return nil
+	}
+}
+
+// WithDeduplication makes Submit deduplicate orders by ID using an in-memory store
+// that remembers each ID for window after its first submission. A zero window
+// remembers IDs for the lifetime of the processor. Orders with a zero ID are never
+// deduplicated. Returns ErrDedupWindowInvalid if window is negative.
+func WithDeduplication(window time.Duration) Option {
+	return func(o *orderProcessor) error {
+		if window < 0 {
+			return ErrDedupWindowInvalid
+		}
+
+		o.dedupWindow = &window
+		return nil
+	}
+}
+
+// WithDedupStore makes Submit deduplicate orders by ID using the given store.
+// Orders with a zero ID are never deduplicated. The store takes precedence over WithDeduplication.
+// Returns ErrDedupStoreInvalid if store is nil.
+func WithDedupStore(store DedupStore) Option {
+	return func(o *orderProcessor) error {
+		if store == nil {
+			return ErrDedupStoreInvalid
+		}
+
+		o.dedup = store
+		return nil
+	}
+}
+
+// WithRetention sets how many finished orders the processor remembers for GetOrderStatus,
+// ListOrders and Cancel. Pending and in-flight orders are always remembered.
+// Returns ErrRetentionInvalid if retention is negative.
+func WithRetention(retention int) Option {
+	return func(o *orderProcessor) error {
+		if retention < 0 {
+			return ErrRetentionInvalid
+		}
+
+		o.retention = retention
+		return nil
+	}
+}
+
+// WithRefundRetention sets how many applied orders the processor remembers for refunds.
+// Once more orders have been applied after an order, it can no longer be refunded and
+// refunds of it fail with ErrRefundOriginalNotFound. The default is 100000.
+// Returns ErrRefundRetentionInvalid if retention is negative.
+func WithRefundRetention(retention int) Option {
+	return func(o *orderProcessor) error {
+		if retention < 0 {
+			return ErrRefundRetentionInvalid
+		}
+
+		o.refundRetention = retention
+		return nil
+	}
+}
@@ -0,0 +1,179 @@
+package processor_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+type countingMetrics struct {
+	mu        sync.Mutex
+	queued    int
+	processed []processor.Outcome
+}
+
+func (m *countingMetrics) OrderQueued(order.Order) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queued++
+}
+
+func (m *countingMetrics) OrderProcessed(outcome processor.Outcome) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed = append(m.processed, outcome)
+}
+
+func (m *countingMetrics) QueueEvicted(int) {}
+
+var _ = Describe("OrderProcessor options", Label("unit"), func() {
+	DescribeTable("creating a processor with an invalid option",
+		func(opt processor.Option, expected error) {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool, opt)
+			Expect(proc).To(BeNil(), "processor should be nil when an option is invalid")
+			Expect(err).To(MatchError(expected), "creating processor should return the option's validation error")
+		},
+		Entry("zero queue capacity", processor.WithQueueCapacity(0), processor.ErrQueueCapacityInvalid),
+		Entry("nil clock", processor.WithClock(nil), processor.ErrClockInvalid),
+		Entry("nil logger", processor.WithLogger(nil), processor.ErrLoggerInvalid),
+		Entry("nil metrics", processor.WithMetrics(nil), processor.ErrMetricsInvalid),
+		Entry("unknown overflow policy", processor.WithOverflowPolicy(processor.OverflowPolicy(-1)), processor.ErrOverflowPolicyInvalid),
+		Entry("negative hold TTL", processor.WithHoldTTL(-time.Second), processor.ErrHoldTTLInvalid),
+		Entry("nil rate provider", processor.WithRateProvider(nil), processor.ErrRateProviderInvalid),
+		Entry("unknown rounding mode", processor.WithRounding(processor.RoundingMode(-1)), processor.ErrRoundingModeInvalid),
+		Entry("rate limit without a burst", processor.WithRateLimit(processor.RateLimit{Rate: 1}), processor.ErrRateLimitInvalid),
+		Entry("negative global rate limit", processor.WithGlobalRateLimit(processor.RateLimit{Rate: -1, Burst: 1}), processor.ErrRateLimitInvalid),
+		Entry("unknown rate limit policy", processor.WithRateLimitPolicy(processor.RateLimitPolicy(-1)), processor.ErrRateLimitPolicyInvalid),
+		Entry("spending limit without a window", processor.WithSpendingLimits(processor.SpendingLimit{Amount: 100}), processor.ErrSpendingLimitInvalid),
+		Entry("zero orders per turn", processor.WithOrdersPerTurn(0), processor.ErrOrdersPerTurnInvalid),
+		Entry("invalid default currency", processor.WithDefaultCurrency("usd"), processor.ErrDefaultCurrencyInvalid),
+		Entry("unknown schedule shutdown policy", processor.WithScheduleShutdown(processor.ScheduleShutdownPolicy(-1)), processor.ErrSchedulePolicyInvalid),
+		Entry("nil schedule store", processor.WithScheduleStore(nil), processor.ErrScheduleStoreInvalid),
+		Entry("persisting without a schedule store", processor.WithScheduleShutdown(processor.SchedulePersist), processor.ErrScheduleStoreInvalid),
+		Entry("nil recurring store", processor.WithRecurringStore(nil), processor.ErrRecurringStoreInvalid),
+		Entry("negative refund retention", processor.WithRefundRetention(-1), processor.ErrRefundRetentionInvalid),
+	)
+
+	When("the overflow policy is OverflowReject", func() {
+		It("should fail fast with ErrQueueFull when the user queue is full", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+			pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(1)
+
+			proc, err := processor.NewOrderProcessor(s, pool,
+				processor.WithQueueCapacity(1),
+				processor.WithOverflowPolicy(processor.OverflowReject),
+			)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})
+			Expect(err).NotTo(HaveOccurred(), "submitting to a queue with room should not return an error")
+
+			err = proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100})
+			Expect(err).To(MatchError(processor.ErrQueueFull), "submitting to a full queue should return ErrQueueFull")
+		})
+	})
+
+	When("draining on shutdown is disabled", func() {
+		It("should fail the pending orders with ErrProcessorShutdown", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+			pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(1)
+			pool.EXPECT().Shutdown().Times(1)
+			pool.EXPECT().Wait().Times(1)
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithDrainOnShutdown(false))
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			future, err := proc.SubmitAsync(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100})
+			Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+
+			proc.Shutdown()
+
+			outcome, err := future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for a resolved future should not return an error")
+			Expect(outcome.Status).To(Equal(processor.StatusFailed), "pending order should be failed")
+			Expect(outcome.Err).To(MatchError(processor.ErrProcessorShutdown), "pending order should fail with ErrProcessorShutdown")
+		})
+
+		It("should cancel the context of the orders in flight", func() {
+			pool, err := worker.NewWorkerPool(1, 10)
+			Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+			proc, err := processor.NewOrderProcessor(storage.NewStorage(), pool,
+				processor.WithHandler(processor.NewDelayedHandler(time.Hour)),
+				processor.WithDrainOnShutdown(false),
+			)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			future, err := proc.SubmitAsync(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100})
+			Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+			Eventually(func() processor.Status { return future.Status().Status }).Should(Equal(processor.StatusProcessing), "a worker should start processing the order")
+
+			done := make(chan struct{})
+			go func() {
+				proc.Shutdown()
+				close(done)
+			}()
+			Eventually(done).Should(BeClosed(), "shutdown should not wait for the handler to finish")
+
+			outcome, err := future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for a resolved future should not return an error")
+			Expect(outcome.Status).To(Equal(processor.StatusFailed), "the order in flight should be failed")
+			Expect(outcome.Err).To(MatchError(context.Canceled), "the order in flight should fail with context.Canceled")
+		})
+	})
+
+	When("a clock and a metrics sink are configured", func() {
+		It("should timestamp outcomes with the clock and report them to the metrics sink", func() {
+			clock := fixedClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			metrics := &countingMetrics{}
+
+			s := storage.NewStorage()
+			pool, err := worker.NewWorkerPool(1, 10)
+			Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+
+			proc, err := processor.NewOrderProcessor(s, pool,
+				processor.WithHandler(processor.NewDelayedHandler(0)),
+				processor.WithClock(clock),
+				processor.WithMetrics(metrics),
+			)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			future, err := proc.SubmitAsync(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100})
+			Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+			proc.Shutdown()
+
+			outcome, err := future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for a resolved future should not return an error")
+			Expect(outcome.SubmittedAt).To(Equal(clock.now), "submit time should come from the clock")
+			Expect(outcome.FinishedAt).To(Equal(clock.now), "finish time should come from the clock")
+			Expect(metrics.queued).To(Equal(1), "metrics should see the queued order")
+			Expect(metrics.processed).To(HaveLen(1), "metrics should see the processed order")
+			Expect(metrics.processed[0].Status).To(Equal(processor.StatusApplied), "metrics should see the applied status")
+		})
+	})
+})
@@ -0,0 +1,50 @@
+package processor
+
+import "time"
+
+const (
+	// defaultOverflowTimeout is how long OverflowBlockTimeout waits for room by default.
+	defaultOverflowTimeout = time.Second
+	// defaultSpillCapacity is how many orders OverflowSpill accepts beyond a full user queue by default.
+	defaultSpillCapacity = 1000
+)
+
+// OverflowPolicy defines what Submit does when the queue of the order's user is full,
+// or when the limit of orders in flight set with WithMaxInFlight is reached.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Submit wait until there is room.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowReject makes Submit fail immediately with ErrQueueFull or ErrTooManyInFlight.
+	OverflowReject
+	// OverflowBlockTimeout makes Submit wait for room no longer than the timeout set with
+	// WithOverflowTimeout, and then fail with ErrQueueFull or ErrTooManyInFlight.
+	OverflowBlockTimeout
+	// OverflowSpill makes Submit accept orders beyond a full user queue, up to the hard cap
+	// set with WithSpillCapacity, and then fail with ErrQueueFull. Orders keep their
+	// submission order. The limit of orders in flight is never exceeded; when it is
+	// reached, Submit fails immediately with ErrTooManyInFlight.
+	OverflowSpill
+)
+
+// valid reports whether the policy is one of the defined policies.
+func (p OverflowPolicy) valid() bool {
+	return p >= OverflowBlock && p <= OverflowSpill
+}
+
+// SubmitOption configures a single call to Submit, SubmitContext or SubmitAsync.
+type SubmitOption func(*submitConfig)
+
+type submitConfig struct {
+	overflowPolicy  OverflowPolicy
+	rateLimitPolicy RateLimitPolicy
+}
+
+// OnOverflow overrides the overflow policy of the processor for a single submit.
+// Submit returns ErrOverflowPolicyInvalid if policy is not one of the defined policies.
+func OnOverflow(policy OverflowPolicy) SubmitOption {
+	return func(c *submitConfig) {
+		c.overflowPolicy = policy
+	}
+}
@@ -0,0 +1,137 @@
+package processor_test
+
+import (
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("Overflow policies", Label("unit"), func() {
+	var (
+		ctrl *gomock.Controller
+		s    *mock.MockUserStorage
+		pool *mock.MockWorkerPool
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		s = mock.NewMockUserStorage(ctrl)
+		pool = mock.NewMockWorkerPool(ctrl)
+		pool.EXPECT().AddTask(gomock.Any()).Return(nil).AnyTimes()
+	})
+
+	When("the overflow policy is OverflowBlockTimeout", func() {
+		It("should wait for the overflow timeout and then fail with ErrQueueFull", func() {
+			timeout := 20 * time.Millisecond
+			proc, err := processor.NewOrderProcessor(s, pool,
+				processor.WithQueueCapacity(1),
+				processor.WithOverflowPolicy(processor.OverflowBlockTimeout),
+				processor.WithOverflowTimeout(timeout),
+			)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+			Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "submitting to a queue with room should succeed")
+
+			before := time.Now()
+			err = proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100})
+			Expect(err).To(MatchError(processor.ErrQueueFull), "submitting to a full queue should fail after the timeout")
+			Expect(time.Since(before)).To(BeNumerically(">=", timeout), "submit should wait for the timeout")
+		})
+	})
+
+	When("the overflow policy is OverflowSpill", func() {
+		It("should accept orders beyond a full queue up to the spill capacity", func() {
+			proc, err := processor.NewOrderProcessor(s, pool,
+				processor.WithQueueCapacity(1),
+				processor.WithSpillCapacity(2),
+				processor.WithOverflowPolicy(processor.OverflowSpill),
+			)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			for id := 1; id <= 3; id++ {
+				Expect(proc.Submit(order.Order{ID: id, UserID: 1, Amount: 100})).To(Succeed(), "submitting within the spill capacity should succeed")
+			}
+
+			err = proc.Submit(order.Order{ID: 4, UserID: 1, Amount: 100})
+			Expect(err).To(MatchError(processor.ErrQueueFull), "submitting beyond the spill capacity should fail")
+
+			orders := proc.ListOrders(1, processor.OrderFilter{Statuses: []processor.Status{processor.StatusQueued}})
+			Expect(orders).To(HaveLen(3), "spilled orders should be queued")
+		})
+	})
+
+	When("overriding the overflow policy for a single submit", func() {
+		It("should fail fast even though the processor blocks by default", func() {
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithQueueCapacity(1))
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+			Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "submitting to a queue with room should succeed")
+
+			err = proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100}, processor.OnOverflow(processor.OverflowReject))
+			Expect(err).To(MatchError(processor.ErrQueueFull), "submitting to a full queue should fail fast")
+		})
+
+		It("should reject an unknown policy", func() {
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100}, processor.OnOverflow(processor.OverflowPolicy(99)))
+			Expect(err).To(MatchError(processor.ErrOverflowPolicyInvalid), "submitting with an unknown policy should fail")
+		})
+	})
+
+	When("the limit of orders in flight is reached", func() {
+		It("should reject orders of any user until an order finishes", func() {
+			proc, err := processor.NewOrderProcessor(s, pool,
+				processor.WithMaxInFlight(2),
+				processor.WithOverflowPolicy(processor.OverflowReject),
+			)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "submitting within the limit should succeed")
+			Expect(proc.Submit(order.Order{ID: 2, UserID: 2, Amount: 100})).To(Succeed(), "submitting within the limit should succeed")
+
+			err = proc.Submit(order.Order{ID: 3, UserID: 3, Amount: 100})
+			Expect(err).To(MatchError(processor.ErrTooManyInFlight), "submitting beyond the limit should fail")
+
+			Expect(proc.Cancel(1)).To(Succeed(), "cancelling a pending order should succeed")
+			Expect(proc.Submit(order.Order{ID: 4, UserID: 3, Amount: 100})).To(Succeed(), "finished order should free its slot")
+		})
+	})
+
+	DescribeTable("creating a processor with an invalid overflow option",
+		func(opt processor.Option, expected error) {
+			proc, err := processor.NewOrderProcessor(s, pool, opt)
+			Expect(proc).To(BeNil(), "processor should be nil when an option is invalid")
+			Expect(err).To(MatchError(expected), "creating processor should return the option's validation error")
+		},
+		Entry("zero overflow timeout", processor.WithOverflowTimeout(0), processor.ErrOverflowTimeoutInvalid),
+		Entry("negative spill capacity", processor.WithSpillCapacity(-1), processor.ErrSpillCapacityInvalid),
+		Entry("zero max in flight", processor.WithMaxInFlight(0), processor.ErrMaxInFlightInvalid),
+	)
+})
+
+var _ = Describe("UserQueue spill", Label("unit"), func() {
+	It("should accept orders beyond the capacity up to the spill capacity", func() {
+		queue := processor.NewUserQueue(1, 1)
+
+		schedule, wait := queue.Push(processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100}))
+		Expect(schedule).To(BeTrue(), "first push should request scheduling")
+		Expect(wait).To(BeNil(), "push to a queue with room should succeed")
+
+		_, wait = queue.Push(processor.NewOrderFuture(order.Order{ID: 2, UserID: 1, Amount: 100}))
+		Expect(wait).NotTo(BeNil(), "push to a full queue should be refused")
+
+		schedule, ok := queue.Spill(processor.NewOrderFuture(order.Order{ID: 2, UserID: 1, Amount: 100}))
+		Expect(ok).To(BeTrue(), "spill within the spill capacity should succeed")
+		Expect(schedule).To(BeFalse(), "spill to a scheduled queue should not request scheduling")
+
+		_, ok = queue.Spill(processor.NewOrderFuture(order.Order{ID: 3, UserID: 1, Amount: 100}))
+		Expect(ok).To(BeFalse(), "spill beyond the spill capacity should be refused")
+		Expect(queue.Len()).To(Equal(2), "queue should hold the pushed and the spilled order")
+	})
+})
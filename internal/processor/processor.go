@@ -1,39 +1,184 @@
 package processor
 
 import (
+	"context"
+	"fmt"
 	"github.com/antoniuk-oleksandr/order_processor/internal/order"
 	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
 	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
+	"log/slog"
 	"sync"
+	"time"
 )
 
 // OrderProcessor handles order submission and processing with user-specific queuing.
 // Orders from the same user are processed sequentially to maintain consistency,
 // while orders from different users are processed concurrently.
 type OrderProcessor interface {
-	// Submit adds an order to the processing queue.
-	// Returns ErrProcessorShutdown if the processor has been shut down.
-	Submit(order order.Order) error
-	// Shutdown gracefully shuts down the processor and waits for all orders to be processed.
+	// Submit validates an order and adds it to the processing queue.
+	// Returns ValidationErrors if the order fails validation,
+	// or ErrProcessorShutdown if the processor has been shut down.
+	Submit(order order.Order, opts ...SubmitOption) error
+	// SubmitContext adds an order to the processing queue, waiting for room no longer than ctx allows.
+	// Returns ErrProcessorShutdown if the processor has been shut down, ErrQueueFull or
+	// ErrTooManyInFlight if there is no room and the overflow policy gives up, ErrRateLimited
+	// if the order is over the rate limit and the rate limit policy rejects it, or an error
+	// wrapping both ErrSubmitCanceled and ctx.Err() if ctx is done before the order is queued.
+	SubmitContext(ctx context.Context, order order.Order, opts ...SubmitOption) error
+	// SubmitAsync adds an order to the processing queue like SubmitContext and
+	// returns a future that resolves once the order has been processed.
+	// If deduplication is enabled and an order with the same ID has already been
+	// submitted, the future of the original order is returned instead.
+	SubmitAsync(ctx context.Context, order order.Order, opts ...SubmitOption) (*OrderFuture, error)
+	// SubmitBatch submits several orders at once and returns one result per order, in the
+	// same order. Orders are grouped by user; the relative order of a user's orders is
+	// preserved and the user's queue is locked once for all orders that fit into it.
+	// A rejected order does not stop the rest of the batch.
+	SubmitBatch(ctx context.Context, orders []order.Order, opts ...SubmitOption) []BatchResult
+	// SubmitAt validates an order and holds it in StatusScheduled until at, when it is added to
+	// the processing queue according to the overflow policy; an order due already is queued as
+	// soon as possible. The order counts against the rate limits when it is submitted.
+	// It can be cancelled with Cancel until it is due. Orders that are not due when the processor
+	// shuts down are settled according to the policy set with WithScheduleShutdown.
+	// Returns ValidationErrors if the order fails validation, ErrRateLimited if it is over the
+	// rate limit and the rate limit policy rejects it, ErrProcessorShutdown if the processor
+	// has been shut down, or an error wrapping both ErrSubmitCanceled and ctx.Err() if ctx is done
+	// while the order waits for the rate limit. If deduplication is enabled and an order with the
+	// same ID has already been submitted, the future of the original order is returned instead.
+	SubmitAt(ctx context.Context, order order.Order, at time.Time, opts ...SubmitOption) (*OrderFuture, error)
+	// CreateSchedule starts generating an order from the template of the schedule at every
+	// occurrence of its recurrence, with the ID of the occurrence as order ID. Occurrences are
+	// submitted like with SubmitAsync once they are due.
+	// Returns ErrScheduleInvalid if the schedule has no positive ID, a valid recurrence or
+	// catch-up policy, ValidationErrors if the template fails validation, ErrScheduleExists
+	// if a schedule with the same ID exists, or ErrProcessorShutdown if the processor has been shut down.
+	CreateSchedule(schedule Schedule) error
+	// ListSchedules returns the schedules generating orders for a user, ordered by ID.
+	ListSchedules(userID int) []ScheduleStatus
+	// PauseSchedule stops the schedule from submitting orders until it is resumed.
+	// Returns ErrScheduleNotFound if there is no schedule with that ID.
+	PauseSchedule(scheduleID int) error
+	// ResumeSchedule lets a paused schedule submit orders again, starting with the next
+	// occurrence due; the occurrences due while it was paused are skipped.
+	// Returns ErrScheduleNotFound if there is no schedule with that ID.
+	ResumeSchedule(scheduleID int) error
+	// DeleteSchedule removes the schedule. Orders it has submitted already are not affected.
+	// Returns ErrScheduleNotFound if there is no schedule with that ID.
+	DeleteSchedule(scheduleID int) error
+	// Shutdown gracefully shuts down the processor and waits for all queued orders to be processed,
+	// or fails the pending ones with ErrProcessorShutdown if draining is disabled with WithDrainOnShutdown.
+	// In that case the context passed to the handler and the rate provider is cancelled as well,
+	// so that orders in flight can be abandoned.
+	// Scheduled orders that are not due yet are settled first, according to WithScheduleShutdown.
 	Shutdown()
-	// GetBalance retrieves the current balance for a user.
-	// Returns the balance and true if the user exists, or 0 and false if not found.
-	GetBalance(userID int) (int, bool)
+	// GetBalance retrieves the current available balance for a user in the given currency,
+	// which excludes held amounts. An empty currency stands for the default currency.
+	// Returns the balance and true if the user has a balance in that currency, or 0 and false if not found.
+	GetBalance(userID int, currency string) (int, bool)
+	// GetBalances retrieves the balances of a user in every currency they have a balance in,
+	// keyed by currency code. Returns an empty map if the user is not found.
+	GetBalances(userID int) map[string]storage.Balance
+	// GetBalanceDetails retrieves both the available balance of a user in the given currency and
+	// the part of it reserved by holds. An empty currency stands for the default currency.
+	// Returns the balance and true if the user has a balance in that currency, or false if not found.
+	GetBalanceDetails(userID int, currency string) (storage.Balance, bool)
+	// SetOverdraftLimit sets how far below zero debit orders may take the user's balance in the given currency.
+	// Debits beyond the limit fail with ErrInsufficientFunds. The default limit is 0.
+	// An empty currency stands for the default currency.
+	// Returns ErrOverdraftLimitInvalid if limit is negative.
+	SetOverdraftLimit(userID int, currency string, limit int) error
+	// SetAccountCurrency sets the currency the user's credits and debits settle into. Orders in
+	// another currency are converted with the rate provider configured with WithRateProvider and
+	// the conversion is recorded in their outcome. An empty currency turns conversion off for the user.
+	// Returns ErrAccountCurrencyInvalid if currency is neither empty nor an ISO-4217 code.
+	SetAccountCurrency(userID int, currency string) error
+	// LiveQueues returns the number of user queues currently held by the processor.
+	LiveQueues() int
+	// Cancel removes a pending order from its user's queue before a worker starts it,
+	// or a scheduled order from the timers before it is due.
+	// The order's future resolves with StatusCancelled and ErrOrderCancelled.
+	// Returns ErrOrderInFlight if a worker is already processing the order, ErrOrderFinished
+	// if it has already been processed, or ErrOrderNotFound if no order with that ID is tracked.
+	// Orders with a zero ID cannot be cancelled.
+	Cancel(orderID int) error
+	// GetOrderStatus returns the lifecycle of the order with the given ID.
+	// Returns false if the order is unknown, has a zero ID, or finished longer ago
+	// than the retention configured with WithRetention.
+	GetOrderStatus(orderID int) (OrderStatus, bool)
+	// ListOrders returns the tracked orders of a user selected by filter, oldest submission first.
+	ListOrders(userID int, filter OrderFilter) []OrderStatus
+	// SetRateLimit overrides the rate limit of the user set with WithRateLimit, taking effect
+	// for the next order of the user. The zero RateLimit lifts the limit for the user.
+	// Returns ErrRateLimitInvalid if limit is neither zero nor has a positive rate and burst.
+	SetRateLimit(userID int, limit RateLimit) error
+	// ResetRateLimit removes the override set with SetRateLimit, so that the user is limited
+	// by the rate limit set with WithRateLimit again.
+	ResetRateLimit(userID int)
 }
 
+const (
+	// defaultQueueCapacity is the default maximum number of pending orders per user.
+	defaultQueueCapacity = 100
+	// defaultIdleTimeout is how long a user's queue may stay idle before it is evicted.
+	defaultIdleTimeout = time.Minute
+	// pruneInterval is how often the state of the rate and spending limits is pruned.
+	pruneInterval = time.Minute
+	// defaultCurrency is the default currency of orders that do not specify one.
+	defaultCurrency = "USD"
+)
+
 type orderProcessor struct {
-	storage      storage.Storage
-	workerPool   worker.WorkerPool
-	shutdownOnce sync.Once
-	userQueues   map[int]chan order.Order
-	userQueuesMu sync.Mutex
-	shutdownChan chan struct{}
-	processorWg  sync.WaitGroup
-}
-
-// NewOrderProcessor creates a new OrderProcessor with the given storage and worker pool.
-// Returns ErrStorageInvalid if storage is nil, or ErrWorkerPoolInvalid if workerPool is nil.
-func NewOrderProcessor(storage storage.Storage, workerPool worker.WorkerPool) (OrderProcessor, error) {
+	storage         storage.Storage
+	workerPool      worker.WorkerPool
+	shutdownOnce    sync.Once
+	userQueues      map[int]UserQueue
+	userQueuesMu    sync.Mutex
+	shutdownChan    chan struct{}
+	processCtx      context.Context
+	cancelProcess   context.CancelFunc
+	processorWg     sync.WaitGroup
+	queueCapacity   int
+	idleTimeout     time.Duration
+	handler         OrderHandler
+	clock           Clock
+	logger          *slog.Logger
+	metrics         Metrics
+	overflowPolicy  OverflowPolicy
+	overflowTimeout time.Duration
+	spillCapacity   int
+	inFlight        chan struct{}
+	drainOnShutdown bool
+	validators      []Validator
+	dedupWindow     *time.Duration
+	dedup           DedupStore
+	retention       int
+	refundRetention int
+	registry        *orderRegistry
+	holdTTL         time.Duration
+	holds           *holdTracker
+	defaultCurrency string
+	converter       *converter
+	limiter         *rateLimiter
+	spendingLimits  []SpendingLimit
+	spending        *spendingTracker
+	ordersPerTurn   int
+	scheduler       *scheduler
+	rateLimitPolicy RateLimitPolicy
+	timers          *timers
+	timersStop      chan struct{}
+	timersDone      chan struct{}
+	timerLanes      *userLanes
+	schedulePolicy  ScheduleShutdownPolicy
+	scheduleStore   ScheduleStore
+	recurring       *recurringSchedules
+	recurringStore  RecurringStore
+	recurringSaveMu sync.Mutex
+}
+
+// NewOrderProcessor creates a new OrderProcessor with the given storage, worker pool and options.
+// Returns ErrStorageInvalid if storage is nil, ErrWorkerPoolInvalid if workerPool is nil,
+// or the error of the first option that fails to apply.
+func NewOrderProcessor(storage storage.Storage, workerPool worker.WorkerPool, opts ...Option) (OrderProcessor, error) {
 	if storage == nil {
 		return nil, ErrStorageInvalid
 	}
@@ -42,61 +187,507 @@ func NewOrderProcessor(storage storage.Storage, workerPool worker.WorkerPool) (O
 		return nil, ErrWorkerPoolInvalid
 	}
 
-	return &orderProcessor{
-		storage:      storage,
-		workerPool:   workerPool,
-		shutdownOnce: sync.Once{},
-		userQueues:   make(map[int]chan order.Order),
-		shutdownChan: make(chan struct{}),
-	}, nil
+	o := &orderProcessor{
+		storage:         storage,
+		workerPool:      workerPool,
+		shutdownOnce:    sync.Once{},
+		userQueues:      make(map[int]UserQueue),
+		shutdownChan:    make(chan struct{}),
+		queueCapacity:   defaultQueueCapacity,
+		idleTimeout:     defaultIdleTimeout,
+		handler:         NewDelayedHandler(defaultProcessingDelay),
+		clock:           systemClock{},
+		logger:          slog.New(slog.DiscardHandler),
+		metrics:         noopMetrics{},
+		overflowPolicy:  OverflowBlock,
+		overflowTimeout: defaultOverflowTimeout,
+		spillCapacity:   defaultSpillCapacity,
+		drainOnShutdown: true,
+		validators:      []Validator{PositiveUserID(), NonZeroAmount(), KnownKind(), KnownPriority(), PositiveDebit(), PositiveCredit(), TransferRecipient(), HoldReference(), RefundReference(), CurrencyCode()},
+		retention:       defaultRetention,
+		refundRetention: defaultRefundRetention,
+		holdTTL:         defaultHoldTTL,
+		holds:           newHoldTracker(),
+		defaultCurrency: defaultCurrency,
+		converter:       newConverter(),
+		limiter:         newRateLimiter(),
+		ordersPerTurn:   defaultOrdersPerTurn,
+		timers:          newTimers(),
+		timersStop:      make(chan struct{}),
+		timersDone:      make(chan struct{}),
+		timerLanes:      newUserLanes(),
+		recurring:       newRecurringSchedules(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.schedulePolicy == SchedulePersist && o.scheduleStore == nil {
+		return nil, ErrScheduleStoreInvalid
+	}
+
+	o.processCtx, o.cancelProcess = context.WithCancel(context.Background())
+	o.registry = newOrderRegistry(o.retention)
+	o.scheduler = newScheduler(o.ordersPerTurn)
+	if len(o.spendingLimits) > 0 {
+		o.spending = newSpendingTracker(o.spendingLimits, o.defaultCurrency, o.clock)
+		o.handler = &spendingHandler{next: o.handler, tracker: o.spending}
+	}
+	o.handler = &ledgerHandler{next: o.handler, ledger: newOrderLedger(o.refundRetention)}
+
+	if o.dedupWindow != nil && o.dedup == nil {
+		o.dedup = NewMemoryDedupStore(*o.dedupWindow, o.clock)
+	}
+
+	if o.recurringStore != nil {
+		if err := o.loadSchedules(); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.scheduleStore != nil {
+		if err := o.loadScheduled(); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.idleTimeout > 0 {
+		o.processorWg.Go(o.evictIdleQueues)
+	}
+
+	if o.holdTTL > 0 {
+		o.processorWg.Go(o.releaseExpiredHolds)
+	}
+
+	// Rate limits can be set for a user with SetRateLimit at any time, so the limits are always pruned.
+	o.processorWg.Go(o.pruneLimits)
+
+	o.processorWg.Go(o.runSchedules)
+	go o.runTimers()
+
+	return o, nil
 }
 
-func (o *orderProcessor) GetBalance(userID int) (int, bool) {
-	return o.storage.Get(userID)
+func (o *orderProcessor) GetBalance(userID int, currency string) (int, bool) {
+	return o.storage.Get(userID, o.currencyOrDefault(currency))
+}
+
+func (o *orderProcessor) GetBalances(userID int) map[string]storage.Balance {
+	return o.storage.Balances(userID)
+}
+
+func (o *orderProcessor) GetBalanceDetails(userID int, currency string) (storage.Balance, bool) {
+	return o.storage.Balance(userID, o.currencyOrDefault(currency))
+}
+
+func (o *orderProcessor) SetOverdraftLimit(userID int, currency string, limit int) error {
+	if limit < 0 {
+		return ErrOverdraftLimitInvalid
+	}
+
+	o.storage.SetOverdraftLimit(userID, o.currencyOrDefault(currency), limit)
+	return nil
+}
+
+func (o *orderProcessor) SetAccountCurrency(userID int, currency string) error {
+	if currency != "" && !isCurrencyCode(currency) {
+		return ErrAccountCurrencyInvalid
+	}
+
+	o.converter.setAccount(userID, currency)
+	return nil
+}
+
+func (o *orderProcessor) SetRateLimit(userID int, limit RateLimit) error {
+	if !limit.valid() {
+		return ErrRateLimitInvalid
+	}
+
+	o.limiter.setOverride(userID, limit)
+	return nil
+}
+
+func (o *orderProcessor) ResetRateLimit(userID int) {
+	o.limiter.clearOverride(userID)
+}
+
+// currencyOrDefault returns the currency, or the default currency if it is empty.
+func (o *orderProcessor) currencyOrDefault(currency string) string {
+	if currency == "" {
+		return o.defaultCurrency
+	}
+	return currency
+}
+
+func (o *orderProcessor) LiveQueues() int {
+	o.userQueuesMu.Lock()
+	defer o.userQueuesMu.Unlock()
+
+	return len(o.userQueues)
 }
 
 func (o *orderProcessor) Shutdown() {
 	o.shutdownOnce.Do(func() {
-		close(o.shutdownChan)
+		o.stopTimers()
 
 		o.userQueuesMu.Lock()
-		for _, queue := range o.userQueues {
-			close(queue)
-		}
+		close(o.shutdownChan)
 		o.userQueuesMu.Unlock()
 
 		o.processorWg.Wait()
 
+		if !o.drainOnShutdown {
+			o.cancelProcess()
+			o.userQueuesMu.Lock()
+			for _, queue := range o.userQueues {
+				o.failPending(queue, ErrProcessorShutdown)
+			}
+			o.userQueuesMu.Unlock()
+		}
+
 		o.workerPool.Shutdown()
 		o.workerPool.Wait()
+		o.cancelProcess()
 	})
 }
 
-func (o *orderProcessor) Submit(ord order.Order) (err error) {
+func (o *orderProcessor) Submit(ord order.Order, opts ...SubmitOption) error {
+	return o.SubmitContext(context.Background(), ord, opts...)
+}
+
+func (o *orderProcessor) SubmitContext(ctx context.Context, ord order.Order, opts ...SubmitOption) error {
+	_, err := o.SubmitAsync(ctx, ord, opts...)
+	return err
+}
+
+func (o *orderProcessor) SubmitAsync(ctx context.Context, ord order.Order, opts ...SubmitOption) (*OrderFuture, error) {
+	result := o.SubmitBatch(ctx, []order.Order{ord}, opts...)[0]
+	return result.Future, result.Err
+}
+
+// reject resolves the future of an order that could not be queued with StatusRejected
+// and records it in the registry. Waiters on a duplicate submit may already hold the
+// future, so it must be resolved. Returns err.
+func (o *orderProcessor) reject(future *OrderFuture, err error) error {
+	if future.reject(err) {
+		o.registry.add(future)
+		o.registry.finish(future)
+	}
+	return err
+}
+
+func (o *orderProcessor) GetOrderStatus(orderID int) (OrderStatus, bool) {
+	future, ok := o.registry.get(orderID)
+	if !ok {
+		return OrderStatus{}, false
+	}
+	return future.Status(), true
+}
+
+func (o *orderProcessor) ListOrders(userID int, filter OrderFilter) []OrderStatus {
+	return o.registry.list(userID, filter)
+}
+
+func (o *orderProcessor) Cancel(orderID int) error {
+	future, ok := o.registry.get(orderID)
+	if !ok {
+		return ErrOrderNotFound
+	}
+
+	if o.timers.remove(future) {
+		o.discard(future, StatusCancelled, ErrOrderCancelled)
+		return nil
+	}
+
+	o.userQueuesMu.Lock()
+	removed := o.remove(future)
+	o.userQueuesMu.Unlock()
+
+	if removed {
+		future.resolve(StatusCancelled, 0, ErrOrderCancelled)
+		return nil
+	}
+
+	select {
+	case <-future.Done():
+		return ErrOrderFinished
+	default:
+		return ErrOrderInFlight
+	}
+}
+
+// enqueue adds the order of the future to its user's queue, waiting for room according
+// to the overflow policy, and hands the queue to the worker pool if it was idle.
+func (o *orderProcessor) enqueue(ctx context.Context, future *OrderFuture, policy OverflowPolicy) (err error) {
+	var expired <-chan time.Time
+	if policy == OverflowBlockTimeout {
+		timer := time.NewTimer(o.overflowTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	if err := o.acquire(ctx, policy, expired); err != nil {
+		return err
+	}
+	queued := false
 	defer func() {
-		if r := recover(); r != nil {
-			err = ErrProcessorShutdown
+		if err != nil && !queued {
+			o.release()
 		}
 	}()
 
-	o.userQueuesMu.Lock()
-	queue, exists := o.userQueues[ord.UserID]
-	if !exists {
-		queue = make(chan order.Order, 100)
-		o.userQueues[ord.UserID] = queue
+	for {
+		idle, wait := o.push(future, policy == OverflowSpill)
+		if wait == nil {
+			// From here on the slot is released when the future resolves,
+			// even if it is failed right away because the worker pool is closed.
+			queued = true
+			o.metrics.OrderQueued(future.Order())
+			for _, queue := range idle {
+				if scheduleErr := o.schedule(queue); scheduleErr != nil {
+					err = scheduleErr
+				}
+			}
+			return err
+		}
 
-		queueTask := NewOrderTask(queue, o.storage)
-		if err := o.workerPool.AddTask(queueTask); err != nil {
-			o.userQueuesMu.Unlock()
+		if policy == OverflowReject || policy == OverflowSpill {
+			return ErrQueueFull
+		}
+
+		select {
+		case <-wait:
+		case <-expired:
+			return ErrQueueFull
+		case <-o.shutdownChan:
 			return ErrProcessorShutdown
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrSubmitCanceled, ctx.Err())
 		}
 	}
-	o.userQueuesMu.Unlock()
+}
+
+// acquire takes a slot for an order in flight, waiting for one according to the overflow policy.
+// It does nothing if the number of orders in flight is not limited.
+func (o *orderProcessor) acquire(ctx context.Context, policy OverflowPolicy, expired <-chan time.Time) error {
+	if o.inFlight == nil {
+		return nil
+	}
+
+	select {
+	case o.inFlight <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if policy == OverflowReject || policy == OverflowSpill {
+		return ErrTooManyInFlight
+	}
 
 	select {
-	case queue <- ord:
+	case o.inFlight <- struct{}{}:
 		return nil
+	case <-expired:
+		return ErrTooManyInFlight
 	case <-o.shutdownChan:
 		return ErrProcessorShutdown
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrSubmitCanceled, ctx.Err())
+	}
+}
+
+// release frees the slot taken by acquire.
+func (o *orderProcessor) release() {
+	if o.inFlight != nil {
+		<-o.inFlight
+	}
+}
+
+// push appends the order to the queue of its user, creating the queue if the user has none.
+// If spill is set, a full queue accepts the order into its spill capacity, and the wait
+// channel is only returned when the spill capacity is exhausted too.
+// Returns the queues that were idle and have to be handed to a worker.
+// The push happens under userQueuesMu so that the queue cannot be evicted in between.
+func (o *orderProcessor) push(future *OrderFuture, spill bool) ([]UserQueue, <-chan struct{}) {
+	o.userQueuesMu.Lock()
+	defer o.userQueuesMu.Unlock()
+
+	if future.Order().Kind == order.KindTransfer {
+		return o.pushTransfer(future, spill)
+	}
+
+	queue := o.queueOf(future.Order().UserID)
+	schedule, wait := queue.Push(future)
+	if wait != nil && spill {
+		var ok bool
+		if schedule, ok = queue.Spill(future); ok {
+			wait = nil
+		}
+	}
+
+	if schedule {
+		return []UserQueue{queue}, wait
+	}
+	if wait == nil {
+		o.scheduler.boost(queue, future.Order().Priority)
+	}
+	return nil, wait
+}
+
+// pushTransfer appends a leg of the transfer to the queues of both users, or to neither of them
+// if one of the queues is full, in which case the wait channel of that queue is returned.
+// It must be called with userQueuesMu held, which keeps other pushers from filling the queues
+// between the check and the push. Workers may still pop concurrently, which only makes room.
+func (o *orderProcessor) pushTransfer(future *OrderFuture, spill bool) ([]UserQueue, <-chan struct{}) {
+	limit := o.queueCapacity
+	if spill {
+		limit += o.spillCapacity
+	}
+
+	ord := future.Order()
+	queues := []UserQueue{o.queueOf(ord.UserID), o.queueOf(ord.To)}
+	for _, queue := range queues {
+		if wait := queue.Wait(limit); wait != nil {
+			return nil, wait
+		}
+	}
+
+	var idle []UserQueue
+	for _, queue := range queues {
+		var schedule bool
+		if queue.Len() < o.queueCapacity {
+			schedule, _ = queue.Push(future)
+		} else {
+			schedule, _ = queue.Spill(future)
+		}
+		if schedule {
+			idle = append(idle, queue)
+			continue
+		}
+		o.scheduler.boost(queue, ord.Priority)
+	}
+	return idle, nil
+}
+
+// remove removes a pending order from the queue of its user, or a pending transfer
+// from the queues of both users. It must be called with userQueuesMu held.
+func (o *orderProcessor) remove(future *OrderFuture) bool {
+	ord := future.Order()
+	if ord.Kind != order.KindTransfer {
+		queue, exists := o.userQueues[ord.UserID]
+		return exists && queue.Remove(future)
+	}
+
+	if !future.withdraw() {
+		return false
+	}
+	for _, userID := range parties(ord) {
+		if queue, exists := o.userQueues[userID]; exists {
+			queue.Remove(future)
+		}
+	}
+	return true
+}
+
+// queueOf returns the queue of the user, creating it if the user has none.
+// It must be called with userQueuesMu held.
+func (o *orderProcessor) queueOf(userID int) UserQueue {
+	queue, exists := o.userQueues[userID]
+	if !exists {
+		queue = newUserQueue(o.queueCapacity, o.spillCapacity, o.clock)
+		o.userQueues[userID] = queue
+	}
+	return queue
+}
+
+// schedule puts an idle queue that has just received an order at the tail of the run queue
+// and hands a turn to the worker pool. If the pool rejects the turn, the pending orders
+// of the queue are failed with ErrProcessorShutdown and the queue is released.
+func (o *orderProcessor) schedule(queue UserQueue) error {
+	o.scheduler.push(queue)
+	if err := o.workerPool.AddTask(newTurnTask(o.processCtx, o.scheduler, o.storage, o.handler, o.converter)); err != nil {
+		o.scheduler.remove(queue)
+		o.failPending(queue, ErrProcessorShutdown)
+		return ErrProcessorShutdown
+	}
+	return nil
+}
+
+// failPending fails all orders still pending in the queue with err.
+func (o *orderProcessor) failPending(queue UserQueue, err error) {
+	for {
+		future, ok := queue.Pop()
+		if !ok {
+			return
+		}
+		future.resolve(StatusFailed, 0, err)
+	}
+}
+
+// observe frees the in-flight slot of a finished order, records that it has finished,
+// reports its outcome to the metrics sink and logs failed orders.
+func (o *orderProcessor) observe(future *OrderFuture, outcome Outcome) {
+	o.release()
+	o.registry.finish(future)
+	if o.holdTTL > 0 {
+		o.holds.observe(outcome, o.holdTTL)
+	}
+	o.metrics.OrderProcessed(outcome)
+
+	if outcome.Status == StatusFailed {
+		o.logger.Warn("order failed",
+			slog.Int("order_id", outcome.Order.ID),
+			slog.Int("user_id", outcome.Order.UserID),
+			slog.Any("error", outcome.Err),
+		)
+	}
+}
+
+// evictIdleQueues periodically removes the queues that have been idle for at least idleTimeout.
+// It runs until the processor is shut down.
+func (o *orderProcessor) evictIdleQueues() {
+	ticker := time.NewTicker(o.idleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.shutdownChan:
+			return
+		case <-ticker.C:
+			now := o.clock.Now()
+			o.userQueuesMu.Lock()
+			for userID, queue := range o.userQueues {
+				if since, idle := queue.IdleSince(); idle && now.Sub(since) >= o.idleTimeout {
+					delete(o.userQueues, userID)
+					o.metrics.QueueEvicted(userID)
+					o.logger.Debug("evicted idle user queue", slog.Int("user_id", userID))
+				}
+			}
+			o.userQueuesMu.Unlock()
+		}
+	}
+}
+
+// pruneLimits periodically drops the rate limit buckets that have refilled and the debits
+// that have left the spending windows, independently of the eviction of idle queues.
+// It runs until the processor is shut down.
+func (o *orderProcessor) pruneLimits() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.shutdownChan:
+			return
+		case <-ticker.C:
+			now := o.clock.Now()
+			o.limiter.prune(now)
+			if o.spending != nil {
+				o.spending.prune(now)
+			}
+		}
 	}
 }
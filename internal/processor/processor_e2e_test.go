@@ -1,11 +1,13 @@
 package processor_test
 
 import (
+	"context"
 	"github.com/antoniuk-oleksandr/order_processor/internal/order"
 	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
 	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
 	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
 	"sync"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -40,15 +42,15 @@ var _ = Describe("OrderProcessor E2E", Label("e2e"), func() {
 
 		processor.Shutdown()
 
-		amount, ok := s.Get(1)
+		amount, ok := s.Get(1, "USD")
 		Expect(ok).To(BeTrue(), "user 1 should exist in storage")
 		Expect(amount).To(Equal(150), "user 1 balance should be 150")
 
-		amount, ok = s.Get(2)
+		amount, ok = s.Get(2, "USD")
 		Expect(ok).To(BeTrue(), "user 2 should exist in storage")
 		Expect(amount).To(Equal(200), "user 2 balance should be 200")
 
-		amount, ok = s.Get(3)
+		amount, ok = s.Get(3, "USD")
 		Expect(ok).To(BeTrue(), "user 3 should exist in storage")
 		Expect(amount).To(Equal(300), "user 3 balance should be 300")
 	})
@@ -100,10 +102,89 @@ var _ = Describe("OrderProcessor E2E", Label("e2e"), func() {
 		
 		proc.Shutdown()
 		for u := 1; u <= numUsers; u++ {
-			amount, ok := s.Get(u)
+			amount, ok := s.Get(u, "USD")
 			Expect(ok).To(BeTrue(), "user %d should exist in storage", u)
 			expected := (numOrdersPerUser * (numOrdersPerUser + 1)) / 2
 			Expect(amount).To(Equal(expected), "user %d balance should be correct", u)
 		}
 	})
+
+	It("should process orders of more users than there are workers", func() {
+		numOfWorker := 2
+		buffer := 10
+
+		s := storage.NewStorage()
+		pool, err := worker.NewWorkerPool(numOfWorker, buffer)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(s, pool)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		numUsers := 10
+		for u := 1; u <= numUsers; u++ {
+			err = proc.Submit(order.Order{ID: u, UserID: u, Amount: 100})
+			Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+		}
+
+		proc.Shutdown()
+		for u := 1; u <= numUsers; u++ {
+			amount, ok := s.Get(u, "USD")
+			Expect(ok).To(BeTrue(), "user %d should exist in storage", u)
+			Expect(amount).To(Equal(100), "user %d balance should be 100", u)
+		}
+	})
+
+	It("should evict idle user queues and recreate them on the next submit", func() {
+		numOfWorker := 2
+		buffer := 10
+		idleTimeout := 50 * time.Millisecond
+
+		s := storage.NewStorage()
+		pool, err := worker.NewWorkerPool(numOfWorker, buffer)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(s, pool, processor.WithIdleTimeout(idleTimeout))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		err = proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})
+		Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+		Expect(proc.LiveQueues()).To(Equal(1), "processor should hold a queue for the user")
+
+		Eventually(proc.LiveQueues).WithTimeout(2*time.Second).Should(BeZero(), "idle queue should be evicted")
+
+		err = proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 50})
+		Expect(err).NotTo(HaveOccurred(), "submitting order after eviction should not return an error")
+
+		proc.Shutdown()
+
+		amount, ok := s.Get(1, "USD")
+		Expect(ok).To(BeTrue(), "user 1 should exist in storage")
+		Expect(amount).To(Equal(150), "user 1 balance should include orders from before and after eviction")
+	})
+
+	It("should resolve order futures without waiting for shutdown", func() {
+		numOfWorker := 2
+		buffer := 10
+
+		s := storage.NewStorage()
+		pool, err := worker.NewWorkerPool(numOfWorker, buffer)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(s, pool)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+		defer proc.Shutdown()
+
+		ctx := context.Background()
+		first, err := proc.SubmitAsync(ctx, order.Order{ID: 1, UserID: 1, Amount: 100})
+		Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+		second, err := proc.SubmitAsync(ctx, order.Order{ID: 2, UserID: 1, Amount: 50})
+		Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+
+		outcome, err := second.Wait(ctx)
+		Expect(err).NotTo(HaveOccurred(), "waiting for the future should not return an error")
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "order should be applied")
+		Expect(outcome.Balance).To(Equal(150), "outcome should carry the post-apply balance")
+		Expect(first.Done()).To(BeClosed(), "earlier order of the same user should be resolved first")
+
+		amount, ok := proc.GetBalance(1, "USD")
+		Expect(ok).To(BeTrue(), "user 1 should exist in storage")
+		Expect(amount).To(Equal(150), "user 1 balance should be 150 before shutdown")
+	})
 })
@@ -1,13 +1,65 @@
 package processor_test
 
 import (
+	"context"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
 )
 
 func TestProcessor(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Processor Suite")
 }
+
+// newTestProcessor creates a processor on s with a real worker pool and a handler without delay,
+// and shuts it down when the spec ends. The options are applied after these defaults.
+func newTestProcessor(s storage.Storage, opts ...processor.Option) processor.OrderProcessor {
+	GinkgoHelper()
+
+	pool, err := worker.NewWorkerPool(2, 10)
+	Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+	proc, err := processor.NewOrderProcessor(s, pool, append([]processor.Option{processor.WithHandler(processor.NewDelayedHandler(0))}, opts...)...)
+	Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+	DeferCleanup(proc.Shutdown)
+	return proc
+}
+
+// apply submits the order and waits for its outcome.
+func apply(proc processor.OrderProcessor, ord order.Order) processor.Outcome {
+	GinkgoHelper()
+
+	future, err := proc.SubmitAsync(context.Background(), ord)
+	Expect(err).NotTo(HaveOccurred(), "submitting order %d should not return an error", ord.ID)
+	outcome, err := future.Wait(context.Background())
+	Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+	return outcome
+}
+
+// submitAll submits the orders as one batch and waits for their outcomes, in the order of the batch.
+func submitAll(proc processor.OrderProcessor, orders ...order.Order) []processor.Outcome {
+	GinkgoHelper()
+
+	var outcomes []processor.Outcome
+	for _, result := range proc.SubmitBatch(context.Background(), orders) {
+		Expect(result.Err).NotTo(HaveOccurred(), "every order should be accepted")
+		outcome, err := result.Future.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
+
+// balanceOf returns a function that reads the balance of the user in USD, to be polled with Eventually.
+func balanceOf(s storage.Storage, userID int) func() int {
+	return func() int {
+		balance, _ := s.Get(userID, "USD")
+		return balance
+	}
+}
@@ -1,6 +1,9 @@
 package processor_test
 
 import (
+	"context"
+	"time"
+
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -46,6 +49,26 @@ var _ = Describe("OrderProcessor", Label("unit"), func() {
 			Expect(proc).To(BeNil(), "processor should be nil when worker pool is nil")
 			Expect(err).To(HaveOccurred(), "creating processor with nil worker pool should return an error")
 		})
+
+		It("should return an error when creating with a negative idle timeout", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithIdleTimeout(-time.Second))
+			Expect(proc).To(BeNil(), "processor should be nil when idle timeout is negative")
+			Expect(err).To(MatchError(processor.ErrIdleTimeoutInvalid), "creating processor with negative idle timeout should return ErrIdleTimeoutInvalid")
+		})
+
+		It("should return an error when creating with a nil handler", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithHandler(nil))
+			Expect(proc).To(BeNil(), "processor should be nil when handler is nil")
+			Expect(err).To(MatchError(processor.ErrHandlerInvalid), "creating processor with nil handler should return ErrHandlerInvalid")
+		})
 	})
 
 	When("submitting an order", func() {
@@ -66,6 +89,7 @@ var _ = Describe("OrderProcessor", Label("unit"), func() {
 
 			err = proc.Submit(order)
 			Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+			Expect(proc.LiveQueues()).To(Equal(1), "processor should hold a queue for the user")
 		})
 
 		It("should return an error when worker pool fails to add a task", func() {
@@ -86,6 +110,51 @@ var _ = Describe("OrderProcessor", Label("unit"), func() {
 
 			err = proc.Submit(order)
 			Expect(err).To(MatchError(processor.ErrProcessorShutdown), "submitting order after shutdown should return ErrProcessorShutdown")
+			Expect(proc.LiveQueues()).To(Equal(1), "processor should keep the released queue for the user")
+		})
+	})
+
+	When("submitting an order with a context", func() {
+		It("should return an error without queueing when the context is already canceled", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(proc).NotTo(BeNil(), "processor should not be nil")
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err = proc.SubmitContext(ctx, order.Order{ID: 1, UserID: 1, Amount: 100})
+			Expect(err).To(MatchError(processor.ErrSubmitCanceled), "submitting with a canceled context should return ErrSubmitCanceled")
+			Expect(err).To(MatchError(context.Canceled), "error should wrap the context error")
+			Expect(proc.LiveQueues()).To(BeZero(), "no queue should be created for a canceled submit")
+		})
+
+		It("should give up when the deadline passes while the user queue is full", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+			pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(1)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(proc).NotTo(BeNil(), "processor should not be nil")
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			for i := range 100 {
+				err = proc.Submit(order.Order{ID: i, UserID: 1, Amount: 100})
+				Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			err = proc.SubmitContext(ctx, order.Order{ID: 100, UserID: 1, Amount: 100})
+			Expect(err).To(MatchError(processor.ErrSubmitCanceled), "submitting to a full queue past the deadline should return ErrSubmitCanceled")
+			Expect(err).To(MatchError(context.DeadlineExceeded), "error should wrap the context error")
+			Expect(err).NotTo(MatchError(processor.ErrProcessorShutdown), "timeout should be distinguishable from shutdown")
 		})
 	})
 
@@ -103,13 +172,13 @@ var _ = Describe("OrderProcessor", Label("unit"), func() {
 			pool := mock.NewMockWorkerPool(ctrl)
 
 			s := mock.NewMockUserStorage(ctrl)
-			s.EXPECT().Get(order.UserID).Return(amout, success).Times(1)
+			s.EXPECT().Get(order.UserID, "USD").Return(amout, success).Times(1)
 
 			proc, err := processor.NewOrderProcessor(s, pool)
 			Expect(proc).NotTo(BeNil(), "processor should not be nil")
 			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
 
-			result, ok := proc.GetBalance(userId)
+			result, ok := proc.GetBalance(userId, "USD")
 			Expect(result).To(Equal(amout), "GetBalance should return the correct balance from storage")
 			Expect(ok).To(BeTrue(), "GetBalance should return true when balance is found")
 		})
@@ -121,18 +190,44 @@ var _ = Describe("OrderProcessor", Label("unit"), func() {
 			pool := mock.NewMockWorkerPool(ctrl)
 
 			s := mock.NewMockUserStorage(ctrl)
-			s.EXPECT().Get(userId).Return(0, false).Times(1)
+			s.EXPECT().Get(userId, "USD").Return(0, false).Times(1)
 
 			proc, err := processor.NewOrderProcessor(s, pool)
 			Expect(proc).NotTo(BeNil(), "processor should not be nil")
 			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
 
-			result, ok := proc.GetBalance(userId)
+			result, ok := proc.GetBalance(userId, "USD")
 			Expect(result).To(Equal(0), "GetBalance should return zero when user is not found in storage")
 			Expect(ok).To(BeFalse(), "GetBalance should return false when user is not found in storage")
 		})
 	})
 
+	When("setting an overdraft limit", func() {
+		It("should pass the limit to storage", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+			s.EXPECT().SetOverdraftLimit(1, "USD", 500).Times(1)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			Expect(proc.SetOverdraftLimit(1, "USD", 500)).To(Succeed(), "setting a valid overdraft limit should succeed")
+		})
+
+		It("should return an error for a negative limit", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.SetOverdraftLimit(1, "USD", -1)
+			Expect(err).To(MatchError(processor.ErrOverdraftLimitInvalid), "negative overdraft limit should be rejected")
+		})
+	})
+
 	When("shutting down the processor", func() {
 		It("should shut down the worker pool successfully", func() {
 			ctrl := gomock.NewController(GinkgoT())
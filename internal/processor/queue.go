@@ -0,0 +1,225 @@
+package processor
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+)
+
+// UserQueue is a bounded FIFO queue of pending orders that belong to a single user.
+// A queue is handed to a worker only while it has pending orders and is released
+// as soon as it is drained, so a fixed-size pool can serve any number of users.
+// A reorderable order is placed ahead of the pending orders with a lower priority
+// at the end of the queue, but a refund never ahead of its original order;
+// all other orders keep their submission order.
+type UserQueue interface {
+	// Push appends an order, represented by its future, to the end of the queue.
+	// Returns schedule=true if the queue was idle and has to be handed to a worker.
+	// If the queue is full, the order is not added and wait is a channel that
+	// is closed once there is room in the queue again.
+	Push(future *OrderFuture) (schedule bool, wait <-chan struct{})
+	// PushAll appends as many of the given orders as fit into the queue, in order.
+	// Returns the number of added orders, and schedule=true if the queue was idle
+	// and has to be handed to a worker.
+	PushAll(futures []*OrderFuture) (n int, schedule bool)
+	// Spill appends an order to the end of the queue even if the queue is full,
+	// as long as no more than the queue's spill capacity of orders exceed its capacity.
+	// Returns schedule=true if the queue was idle and has to be handed to a worker,
+	// or ok=false if the order was not added.
+	Spill(future *OrderFuture) (schedule bool, ok bool)
+	// Pop removes and returns the next pending order.
+	// Returns false and marks the queue as idle once there are no pending orders.
+	Pop() (*OrderFuture, bool)
+	// Remove removes a pending order from the queue.
+	// Returns false if the order is not pending in the queue, e.g. because a worker has already taken it.
+	Remove(future *OrderFuture) bool
+	// Len returns the number of pending orders.
+	Len() int
+	// Wait returns nil if the queue holds fewer than limit pending orders, or a channel
+	// that is closed once there is room in the queue again. limit must be at least the capacity.
+	// The check and the choice of channel happen atomically, so a concurrent Pop cannot be missed.
+	Wait(limit int) <-chan struct{}
+	// Priority returns the highest priority of the pending orders,
+	// or order.PriorityLow if there are none.
+	Priority() order.Priority
+	// IdleSince returns the time of the last push or drain of the queue,
+	// and true if the queue has no pending orders and is not handed to a worker.
+	IdleSince() (time.Time, bool)
+}
+
+type userQueue struct {
+	mu         sync.Mutex
+	orders     []*OrderFuture
+	capacity   int
+	spill      int
+	scheduled  bool
+	space      chan struct{}
+	lastActive time.Time
+	clock      Clock
+}
+
+// NewUserQueue creates a new idle UserQueue that holds up to capacity pending orders,
+// plus up to spill orders added with Spill.
+func NewUserQueue(capacity, spill int) UserQueue {
+	return newUserQueue(capacity, spill, systemClock{})
+}
+
+// newUserQueue creates a new idle UserQueue that measures its idle time with clock.
+func newUserQueue(capacity, spill int, clock Clock) UserQueue {
+	return &userQueue{
+		orders:     make([]*OrderFuture, 0, capacity),
+		capacity:   capacity,
+		spill:      spill,
+		space:      make(chan struct{}),
+		lastActive: clock.Now(),
+		clock:      clock,
+	}
+}
+
+func (q *userQueue) Push(future *OrderFuture) (bool, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.orders) >= q.capacity {
+		return false, q.space
+	}
+
+	return q.append(future), nil
+}
+
+func (q *userQueue) PushAll(futures []*OrderFuture) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := min(len(futures), q.capacity-len(q.orders))
+	schedule := false
+	for _, future := range futures[:max(n, 0)] {
+		schedule = q.append(future) || schedule
+	}
+	return max(n, 0), schedule
+}
+
+func (q *userQueue) Spill(future *OrderFuture) (bool, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.orders) >= q.capacity+q.spill {
+		return false, false
+	}
+
+	return q.append(future), true
+}
+
+// append adds the order to the end of the queue, or ahead of the pending orders with
+// a lower priority at the end of the queue if it is reorderable, and marks the queue as scheduled.
+// Returns true if the queue was idle. It must be called with mu held.
+func (q *userQueue) append(future *OrderFuture) bool {
+	at := len(q.orders)
+	if ord := future.Order(); ord.Reorderable && ord.Kind != order.KindTransfer {
+		for at > 0 && q.orders[at-1].Order().Priority < ord.Priority && !refunds(ord, q.orders[at-1].Order()) {
+			at--
+		}
+	}
+	q.orders = slices.Insert(q.orders, at, future)
+	future.queue()
+	q.lastActive = q.clock.Now()
+	if q.scheduled {
+		return false
+	}
+
+	q.scheduled = true
+	return true
+}
+
+// refunds reports whether ord is a refund of original.
+func refunds(ord, original order.Order) bool {
+	return ord.Kind == order.KindRefund && ord.OriginalOrderID == original.ID
+}
+
+func (q *userQueue) Pop() (*OrderFuture, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.orders) == 0 {
+		q.scheduled = false
+		q.lastActive = q.clock.Now()
+		return nil, false
+	}
+
+	wasFull := len(q.orders) >= q.capacity
+	future := q.orders[0]
+	q.orders[0] = nil
+	q.orders = q.orders[1:]
+
+	if wasFull {
+		q.signalSpace()
+	}
+
+	return future, true
+}
+
+func (q *userQueue) Remove(future *OrderFuture) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, pending := range q.orders {
+		if pending != future {
+			continue
+		}
+
+		wasFull := len(q.orders) >= q.capacity
+		copy(q.orders[i:], q.orders[i+1:])
+		q.orders[len(q.orders)-1] = nil
+		q.orders = q.orders[:len(q.orders)-1]
+
+		if wasFull {
+			q.signalSpace()
+		}
+		return true
+	}
+
+	return false
+}
+
+// signalSpace wakes up the pushers waiting for room in the queue. It must be called with mu held.
+func (q *userQueue) signalSpace() {
+	close(q.space)
+	q.space = make(chan struct{})
+}
+
+func (q *userQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.orders)
+}
+
+func (q *userQueue) Wait(limit int) <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.orders) < limit {
+		return nil
+	}
+	return q.space
+}
+
+func (q *userQueue) Priority() order.Priority {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	priority := order.PriorityLow
+	for _, future := range q.orders {
+		priority = max(priority, future.Order().Priority)
+	}
+	return priority
+}
+
+func (q *userQueue) IdleSince() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.lastActive, len(q.orders) == 0 && !q.scheduled
+}
@@ -0,0 +1,150 @@
+package processor_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+)
+
+var _ = Describe("UserQueue", Label("unit"), func() {
+	When("pushing orders to an idle queue", func() {
+		It("should request scheduling only for the first order", func() {
+			queue := processor.NewUserQueue(10, 0)
+
+			schedule, wait := queue.Push(processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100}))
+			Expect(schedule).To(BeTrue(), "first push to an idle queue should request scheduling")
+			Expect(wait).To(BeNil(), "push to a queue with room should not return a wait channel")
+
+			schedule, wait = queue.Push(processor.NewOrderFuture(order.Order{ID: 2, UserID: 1, Amount: 100}))
+			Expect(schedule).To(BeFalse(), "push to an already scheduled queue should not request scheduling")
+			Expect(wait).To(BeNil(), "push to a queue with room should not return a wait channel")
+			Expect(queue.Len()).To(Equal(2), "queue should hold both orders")
+		})
+	})
+
+	When("the queue is full", func() {
+		It("should reject the order and signal once there is room", func() {
+			queue := processor.NewUserQueue(1, 0)
+			queue.Push(processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100}))
+
+			schedule, wait := queue.Push(processor.NewOrderFuture(order.Order{ID: 2, UserID: 1, Amount: 100}))
+			Expect(schedule).To(BeFalse(), "rejected push should not request scheduling")
+			Expect(wait).NotTo(BeNil(), "push to a full queue should return a wait channel")
+			Expect(queue.Len()).To(Equal(1), "rejected order should not be added")
+
+			_, ok := queue.Pop()
+			Expect(ok).To(BeTrue(), "pop should return the pending order")
+			Expect(wait).To(BeClosed(), "wait channel should be closed once there is room")
+		})
+
+		It("should return a wait channel without pushing until there is room", func() {
+			queue := processor.NewUserQueue(1, 0)
+			queue.Push(processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100}))
+
+			wait := queue.Wait(1)
+			Expect(wait).NotTo(BeNil(), "a full queue should return a wait channel")
+			Expect(queue.Len()).To(Equal(1), "waiting should not add an order")
+
+			queue.Pop()
+			Expect(wait).To(BeClosed(), "wait channel should be closed once there is room")
+			Expect(queue.Wait(1)).To(BeNil(), "a queue with room should not return a wait channel")
+		})
+	})
+
+	When("popping orders", func() {
+		It("should return orders in submission order and release the queue once drained", func() {
+			queue := processor.NewUserQueue(10, 0)
+			for i := 1; i <= 3; i++ {
+				queue.Push(processor.NewOrderFuture(order.Order{ID: i, UserID: 1, Amount: 100}))
+			}
+
+			for i := 1; i <= 3; i++ {
+				future, ok := queue.Pop()
+				Expect(ok).To(BeTrue(), "pop should return a pending order")
+				Expect(future.Order().ID).To(Equal(i), "orders should be popped in submission order")
+			}
+
+			_, ok := queue.Pop()
+			Expect(ok).To(BeFalse(), "pop from a drained queue should return false")
+
+			schedule, _ := queue.Push(processor.NewOrderFuture(order.Order{ID: 4, UserID: 1, Amount: 100}))
+			Expect(schedule).To(BeTrue(), "push to a released queue should request scheduling again")
+		})
+	})
+
+	When("checking whether the queue is idle", func() {
+		It("should report idle only when drained and not handed to a worker", func() {
+			queue := processor.NewUserQueue(10, 0)
+
+			_, idle := queue.IdleSince()
+			Expect(idle).To(BeTrue(), "new queue should be idle")
+
+			queue.Push(processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100}))
+			_, idle = queue.IdleSince()
+			Expect(idle).To(BeFalse(), "queue with pending orders should not be idle")
+
+			queue.Pop()
+			_, idle = queue.IdleSince()
+			Expect(idle).To(BeFalse(), "queue handed to a worker should not be idle")
+
+			before := time.Now()
+			queue.Pop()
+			since, idle := queue.IdleSince()
+			Expect(idle).To(BeTrue(), "drained queue should be idle")
+			Expect(since).To(BeTemporally(">=", before), "idle time should be the time of the drain")
+		})
+	})
+
+	When("pushing orders of different priorities", func() {
+		It("should move only reorderable orders ahead of lower priorities", func() {
+			queue := processor.NewUserQueue(10, 0)
+			push := func(id int, priority order.Priority, reorderable bool) {
+				queue.Push(processor.NewOrderFuture(order.Order{ID: id, UserID: 1, Amount: 100, Priority: priority, Reorderable: reorderable}))
+			}
+
+			push(1, order.PriorityNormal, false)
+			push(2, order.PriorityLow, false)
+			push(3, order.PriorityLow, false)
+			push(4, order.PriorityHigh, false)
+			push(5, order.PriorityNormal, true)
+			push(6, order.PriorityHigh, true)
+			push(7, order.PriorityHigh, true)
+			Expect(queue.Priority()).To(Equal(order.PriorityHigh), "queue priority should be the highest pending priority")
+
+			var ids []int
+			for {
+				future, ok := queue.Pop()
+				if !ok {
+					break
+				}
+				ids = append(ids, future.Order().ID)
+			}
+			Expect(ids).To(Equal([]int{1, 2, 3, 4, 6, 7, 5}), "reorderable orders should only pass lower priorities at the end of the queue")
+			Expect(queue.Priority()).To(Equal(order.PriorityLow), "a drained queue should have the lowest priority")
+		})
+
+		It("should not move a reorderable refund ahead of its original order", func() {
+			queue := processor.NewUserQueue(10, 0)
+			queue.Push(processor.NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100, Priority: order.PriorityLow}))
+			queue.Push(processor.NewOrderFuture(order.Order{ID: 2, UserID: 1, Amount: 100, Priority: order.PriorityLow}))
+			refund := order.Refund{ID: 3, UserID: 1, OriginalOrderID: 1}.Order()
+			refund.Priority = order.PriorityHigh
+			refund.Reorderable = true
+			queue.Push(processor.NewOrderFuture(refund))
+
+			var ids []int
+			for {
+				future, ok := queue.Pop()
+				if !ok {
+					break
+				}
+				ids = append(ids, future.Order().ID)
+			}
+			Expect(ids).To(Equal([]int{1, 3, 2}), "the refund should pass lower priorities only behind its original order")
+		})
+	})
+})
@@ -0,0 +1,255 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimit is the rate of a token bucket that limits how fast orders are submitted.
+// The zero RateLimit does not limit the rate.
+type RateLimit struct {
+	// Rate is the sustained number of orders per second.
+	Rate float64
+	// Burst is the number of orders that can be submitted at once after a quiet period.
+	Burst int
+}
+
+// valid reports whether the limit is either the zero RateLimit or has a positive rate and burst.
+func (l RateLimit) valid() bool {
+	if l == (RateLimit{}) {
+		return true
+	}
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// unlimited reports whether the limit does not limit the rate.
+func (l RateLimit) unlimited() bool {
+	return l == (RateLimit{})
+}
+
+// RateLimitPolicy defines what Submit does with an order over the rate limit.
+type RateLimitPolicy int
+
+const (
+	// RateLimitReject makes Submit fail immediately with ErrRateLimited.
+	RateLimitReject RateLimitPolicy = iota
+	// RateLimitDelay makes Submit wait until the order is within the rate limit,
+	// or the context passed to SubmitContext is done.
+	RateLimitDelay
+)
+
+// valid reports whether the policy is one of the defined policies.
+func (p RateLimitPolicy) valid() bool {
+	return p == RateLimitReject || p == RateLimitDelay
+}
+
+// OnRateLimit overrides the rate limit policy of the processor for a single submit.
+// Submit returns ErrRateLimitPolicyInvalid if policy is not one of the defined policies.
+func OnRateLimit(policy RateLimitPolicy) SubmitOption {
+	return func(c *submitConfig) {
+		c.rateLimitPolicy = policy
+	}
+}
+
+// tokenBucket holds up to burst tokens and refills at rate tokens per second.
+// Its tokens go negative while orders wait for a delayed submit.
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// refill adds the tokens accumulated since the last refill.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(float64(b.limit.Burst), b.tokens+elapsed.Seconds()*b.limit.Rate)
+		b.last = now
+	}
+}
+
+// available reports whether a token can be taken without waiting.
+func (b *tokenBucket) available(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= 1
+}
+
+// take takes a token and returns how long to wait until it is actually available.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.limit.Rate * float64(time.Second))
+}
+
+// full reports whether the bucket has refilled completely, so that it is
+// indistinguishable from a new one.
+func (b *tokenBucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+// reset changes the limit of the bucket, keeping no more tokens than the new burst.
+func (b *tokenBucket) reset(limit RateLimit) {
+	b.limit = limit
+	b.tokens = min(b.tokens, float64(limit.Burst))
+}
+
+// rateLimiter limits the rate of orders per user, with per-user overrides,
+// and optionally across all users.
+type rateLimiter struct {
+	mu        sync.Mutex
+	user      RateLimit
+	global    RateLimit
+	overrides map[int]RateLimit
+	buckets   map[int]*tokenBucket
+	shared    *tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		overrides: make(map[int]RateLimit),
+		buckets:   make(map[int]*tokenBucket),
+	}
+}
+
+// limitOf returns the rate limit of the user. It must be called with mu held.
+func (l *rateLimiter) limitOf(userID int) RateLimit {
+	if limit, ok := l.overrides[userID]; ok {
+		return limit
+	}
+	return l.user
+}
+
+// bucketOf returns the token bucket of the user, or nil if the user's rate is not limited.
+// It must be called with mu held.
+func (l *rateLimiter) bucketOf(userID int, now time.Time) *tokenBucket {
+	limit := l.limitOf(userID)
+	if limit.unlimited() {
+		return nil
+	}
+
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = newTokenBucket(limit, now)
+		l.buckets[userID] = bucket
+	}
+	return bucket
+}
+
+// reserve takes a token for an order of the user from both the user's and the global bucket.
+// With RateLimitReject it takes no token and returns ErrRateLimited unless both have one available;
+// with RateLimitDelay it always takes them and returns how long to wait before the order is within the limit.
+// The returned cancel puts the tokens back if the order is not submitted after all.
+func (l *rateLimiter) reserve(userID int, policy RateLimitPolicy, now time.Time) (time.Duration, func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var buckets []*tokenBucket
+	if bucket := l.bucketOf(userID, now); bucket != nil {
+		buckets = append(buckets, bucket)
+	}
+	if !l.global.unlimited() {
+		if l.shared == nil {
+			l.shared = newTokenBucket(l.global, now)
+		}
+		buckets = append(buckets, l.shared)
+	}
+
+	if policy == RateLimitReject {
+		for _, bucket := range buckets {
+			if !bucket.available(now) {
+				return 0, nil, fmt.Errorf("%w: user %d", ErrRateLimited, userID)
+			}
+		}
+	}
+
+	var wait time.Duration
+	for _, bucket := range buckets {
+		wait = max(wait, bucket.take(now))
+	}
+
+	cancel := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		for _, bucket := range buckets {
+			bucket.tokens = min(float64(bucket.limit.Burst), bucket.tokens+1)
+		}
+	}
+	return wait, cancel, nil
+}
+
+// setOverride sets the rate limit of the user, replacing the default one.
+func (l *rateLimiter) setOverride(userID int, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overrides[userID] = limit
+	l.resetBucket(userID, limit)
+}
+
+// clearOverride makes the user fall back to the default rate limit.
+func (l *rateLimiter) clearOverride(userID int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.overrides, userID)
+	l.resetBucket(userID, l.user)
+}
+
+// resetBucket applies a changed limit to the bucket of the user. It must be called with mu held.
+func (l *rateLimiter) resetBucket(userID int, limit RateLimit) {
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		return
+	}
+	if limit.unlimited() {
+		delete(l.buckets, userID)
+		return
+	}
+	bucket.reset(limit)
+}
+
+// prune drops the buckets that have refilled completely, since they would be recreated identical.
+func (l *rateLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for userID, bucket := range l.buckets {
+		if bucket.full(now) {
+			delete(l.buckets, userID)
+		}
+	}
+}
+
+// throttle admits an order of the user according to the rate limits and policy.
+// With RateLimitDelay it waits until the order is within the limits, giving up when ctx is done
+// or the processor is shut down.
+func (o *orderProcessor) throttle(ctx context.Context, userID int, policy RateLimitPolicy) error {
+	wait, cancel, err := o.limiter.reserve(userID, policy, o.clock.Now())
+	if err != nil || wait == 0 {
+		return err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-o.shutdownChan:
+		cancel()
+		return ErrProcessorShutdown
+	case <-ctx.Done():
+		cancel()
+		return fmt.Errorf("%w: %w", ErrSubmitCanceled, ctx.Err())
+	}
+}
@@ -0,0 +1,131 @@
+package processor_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+)
+
+var _ = Describe("Rate limiting", Label("unit"), func() {
+	var clock *manualClock
+
+	BeforeEach(func() {
+		clock = &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	})
+
+	It("should reject orders of a user beyond the burst until tokens refill", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithClock(clock), processor.WithRateLimit(processor.RateLimit{Rate: 1, Burst: 2}))
+
+		Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "the first order should be within the burst")
+		Expect(proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100})).To(Succeed(), "the second order should be within the burst")
+		Expect(proc.Submit(order.Order{ID: 3, UserID: 1, Amount: 100})).To(MatchError(processor.ErrRateLimited), "an order beyond the burst should be rate limited")
+		Expect(proc.Submit(order.Order{ID: 4, UserID: 2, Amount: 100})).To(Succeed(), "another user should have their own bucket")
+
+		clock.Advance(time.Second)
+		Expect(proc.Submit(order.Order{ID: 5, UserID: 1, Amount: 100})).To(Succeed(), "a refilled token should admit the order")
+
+		status, ok := proc.GetOrderStatus(3)
+		Expect(ok).To(BeTrue(), "the rate limited order should be tracked")
+		Expect(status.Status).To(Equal(processor.StatusRejected), "the rate limited order should be rejected")
+	})
+
+	It("should limit all users together with a global rate limit", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithClock(clock), processor.WithGlobalRateLimit(processor.RateLimit{Rate: 1, Burst: 1}))
+
+		Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "the first order should be within the global burst")
+		Expect(proc.Submit(order.Order{ID: 2, UserID: 2, Amount: 100})).To(MatchError(processor.ErrRateLimited), "an order of another user should count against the global limit")
+	})
+
+	It("should apply per-user overrides at runtime", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithClock(clock), processor.WithRateLimit(processor.RateLimit{Rate: 1, Burst: 1}))
+
+		Expect(proc.SetRateLimit(1, processor.RateLimit{})).To(Succeed(), "lifting the limit of a user should succeed")
+		for id := 1; id <= 5; id++ {
+			Expect(proc.Submit(order.Order{ID: id, UserID: 1, Amount: 100})).To(Succeed(), "a user without a limit should never be rate limited")
+		}
+
+		proc.ResetRateLimit(1)
+		Expect(proc.Submit(order.Order{ID: 6, UserID: 1, Amount: 100})).To(Succeed(), "the default burst should admit one order")
+		Expect(proc.Submit(order.Order{ID: 7, UserID: 1, Amount: 100})).To(MatchError(processor.ErrRateLimited), "the default limit should apply again")
+
+		Expect(proc.SetRateLimit(1, processor.RateLimit{Rate: 1})).To(MatchError(processor.ErrRateLimitInvalid), "a limit without a burst should be invalid")
+	})
+
+	It("should not let a rate limited order take the place of a deduplicated one", func() {
+		proc := newTestProcessor(storage.NewStorage(),
+			processor.WithClock(clock),
+			processor.WithRateLimit(processor.RateLimit{Rate: 1, Burst: 1}),
+			processor.WithDeduplication(time.Minute),
+		)
+
+		Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "the first order should be within the burst")
+		Expect(proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100})).To(MatchError(processor.ErrRateLimited), "an order beyond the burst should be rate limited")
+
+		clock.Advance(time.Second)
+		Expect(proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100})).To(Succeed(), "a retry of the rate limited order should not be a duplicate")
+	})
+
+	It("should give up waiting when the context is done with RateLimitDelay", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithClock(clock), processor.WithRateLimit(processor.RateLimit{Rate: 0.001, Burst: 1}))
+
+		Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "the first order should be within the burst")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := proc.SubmitContext(ctx, order.Order{ID: 2, UserID: 1, Amount: 100}, processor.OnRateLimit(processor.RateLimitDelay))
+		Expect(err).To(MatchError(processor.ErrSubmitCanceled), "waiting for a token should give up with the context")
+		Expect(err).To(MatchError(context.DeadlineExceeded), "the error should wrap the context error")
+	})
+
+	It("should give up scheduling an order when the context is done with RateLimitDelay", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithClock(clock), processor.WithRateLimit(processor.RateLimit{Rate: 0.001, Burst: 1}))
+
+		Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "the first order should be within the burst")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		future, err := proc.SubmitAt(ctx, order.Order{ID: 2, UserID: 1, Amount: 100}, clock.Now().Add(time.Hour), processor.OnRateLimit(processor.RateLimitDelay))
+		Expect(err).To(MatchError(processor.ErrSubmitCanceled), "waiting for a token should give up with the context")
+		Expect(err).To(MatchError(context.DeadlineExceeded), "the error should wrap the context error")
+		Expect(future).To(BeNil(), "no future should be returned for a cancelled order")
+	})
+
+	It("should reject an unknown rate limit policy for a single submit", func() {
+		proc := newTestProcessor(storage.NewStorage(), processor.WithClock(clock))
+
+		err := proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100}, processor.OnRateLimit(processor.RateLimitPolicy(-1)))
+		Expect(err).To(MatchError(processor.ErrRateLimitPolicyInvalid), "an unknown policy should be rejected")
+	})
+})
+
+var _ = Describe("Rate limiting E2E", Label("e2e"), func() {
+	It("should delay orders over the limit with RateLimitDelay instead of rejecting them", func() {
+		proc := newTestProcessor(storage.NewStorage(),
+			processor.WithRateLimit(processor.RateLimit{Rate: 20, Burst: 1}),
+			processor.WithRateLimitPolicy(processor.RateLimitDelay),
+		)
+
+		start := time.Now()
+		var futures []*processor.OrderFuture
+		for id := 1; id <= 5; id++ {
+			future, err := proc.SubmitAsync(context.Background(), order.Order{ID: id, UserID: 1, Amount: 100})
+			Expect(err).NotTo(HaveOccurred(), "a delayed order should be accepted")
+			futures = append(futures, future)
+		}
+		Expect(time.Since(start)).To(BeNumerically(">=", 190*time.Millisecond), "four orders beyond the burst should wait for 50ms each")
+
+		for _, future := range futures {
+			outcome, err := future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+			Expect(outcome.Status).To(Equal(processor.StatusApplied), "every delayed order should be applied")
+		}
+		balance, _ := proc.GetBalance(1, "")
+		Expect(balance).To(Equal(500), "every delayed order should reach the balance")
+	})
+})
@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Rate is an exchange rate between two currencies.
+type Rate struct {
+	// Value is how many units of the target currency one unit of the source currency is worth.
+	// Units are whole currency units, e.g. euros rather than cents.
+	Value *big.Rat
+	// Source describes where the rate comes from, e.g. the path of a rate file.
+	Source string
+}
+
+// RateProvider looks up exchange rates used to settle orders in their user's account currency.
+type RateProvider interface {
+	// Rate returns the rate to convert an amount in the from currency into the to currency.
+	// Returns an error matching ErrRateNotFound if the provider has no such rate.
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+// CurrencyPair identifies the direction of an exchange rate.
+type CurrencyPair struct {
+	// From is the ISO-4217 code of the source currency.
+	From string
+	// To is the ISO-4217 code of the target currency.
+	To string
+}
+
+type staticRateProvider struct {
+	source string
+	rates  map[CurrencyPair]*big.Rat
+}
+
+// NewStaticRateProvider creates a RateProvider that serves a fixed set of rates, given as
+// exact decimal strings such as "1.0842", and reports source as the source of every rate.
+// Returns ErrRateInvalid if a pair is not made of ISO-4217 codes or a rate is not a positive decimal.
+func NewStaticRateProvider(source string, rates map[CurrencyPair]string) (RateProvider, error) {
+	p := &staticRateProvider{
+		source: source,
+		rates:  make(map[CurrencyPair]*big.Rat, len(rates)),
+	}
+
+	for pair, rate := range rates {
+		value, err := parseRate(pair, rate)
+		if err != nil {
+			return nil, err
+		}
+		p.rates[pair] = value
+	}
+	return p, nil
+}
+
+// LoadRateFile creates a static RateProvider from a file with one rate per line in the form
+// "EUR USD 1.0842", meaning that one euro is worth 1.0842 dollars. Blank lines and lines starting
+// with # are ignored. The path of the file is reported as the source of every rate.
+// Returns ErrRateInvalid, annotated with the line number, if a line cannot be parsed.
+func LoadRateFile(path string) (RateProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rates := make(map[CurrencyPair]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: %w: expected \"FROM TO RATE\"", path, line, ErrRateInvalid)
+		}
+
+		pair := CurrencyPair{From: fields[0], To: fields[1]}
+		if _, err := parseRate(pair, fields[2]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rates[pair] = fields[2]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewStaticRateProvider(path, rates)
+}
+
+func (p *staticRateProvider) Rate(_ context.Context, from, to string) (Rate, error) {
+	value, ok := p.rates[CurrencyPair{From: from, To: to}]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+	}
+	return Rate{Value: new(big.Rat).Set(value), Source: p.source}, nil
+}
+
+// parseRate parses the exact decimal rate of the pair.
+func parseRate(pair CurrencyPair, rate string) (*big.Rat, error) {
+	if !isCurrencyCode(pair.From) || !isCurrencyCode(pair.To) {
+		return nil, fmt.Errorf("%w: %s to %s is not a pair of ISO-4217 codes", ErrRateInvalid, pair.From, pair.To)
+	}
+
+	value, ok := new(big.Rat).SetString(rate)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %q is not a positive decimal", ErrRateInvalid, rate)
+	}
+	return value, nil
+}
@@ -0,0 +1,221 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinInterval is the shortest interval of an Every recurrence.
+const MinInterval = time.Second
+
+// recurrenceKind is the kind of calendar a Recurrence follows.
+type recurrenceKind int
+
+const (
+	recurEvery recurrenceKind = iota + 1
+	recurDaily
+	recurMonthly
+)
+
+// Recurrence defines when the occurrences of a Schedule are due.
+// Create one with Every, Daily, Monthly or ParseRecurrence.
+type Recurrence struct {
+	kind     recurrenceKind
+	interval time.Duration
+	day      int
+	hour     int
+	minute   int
+}
+
+// Every recurs at a fixed interval, counted from the start of the schedule.
+// The interval must be at least MinInterval.
+func Every(interval time.Duration) Recurrence {
+	return Recurrence{kind: recurEvery, interval: interval}
+}
+
+// Daily recurs every day at hour:minute in the location of the start of the schedule.
+func Daily(hour, minute int) Recurrence {
+	return Recurrence{kind: recurDaily, hour: hour, minute: minute}
+}
+
+// Monthly recurs every month on the given day at hour:minute in the location of the start
+// of the schedule. In months shorter than day, it recurs on the last day of the month.
+func Monthly(day, hour, minute int) Recurrence {
+	return Recurrence{kind: recurMonthly, day: day, hour: hour, minute: minute}
+}
+
+// ParseRecurrence parses a recurrence written as one of:
+//
+//	every 15m          Every(15 * time.Minute), with any time.ParseDuration duration of at least MinInterval
+//	daily 09:30        Daily(9, 30)
+//	monthly 31 09:30   Monthly(31, 9, 30)
+//
+// Returns an error wrapping ErrRecurrenceInvalid if expr is none of these.
+func ParseRecurrence(expr string) (Recurrence, error) {
+	fields := strings.Fields(expr)
+	invalid := fmt.Errorf("%w: %q", ErrRecurrenceInvalid, expr)
+
+	var r Recurrence
+	switch {
+	case len(fields) == 2 && fields[0] == "every":
+		interval, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return Recurrence{}, invalid
+		}
+		r = Every(interval)
+	case len(fields) == 2 && fields[0] == "daily":
+		hour, minute, ok := parseClock(fields[1])
+		if !ok {
+			return Recurrence{}, invalid
+		}
+		r = Daily(hour, minute)
+	case len(fields) == 3 && fields[0] == "monthly":
+		day, err := strconv.Atoi(fields[1])
+		hour, minute, ok := parseClock(fields[2])
+		if err != nil || !ok {
+			return Recurrence{}, invalid
+		}
+		r = Monthly(day, hour, minute)
+	default:
+		return Recurrence{}, invalid
+	}
+
+	if !r.valid() {
+		return Recurrence{}, invalid
+	}
+	return r, nil
+}
+
+// parseClock parses a time of day written as hh:mm.
+func parseClock(s string) (int, int, bool) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(hh)
+	if err != nil {
+		return 0, 0, false
+	}
+	minute, err := strconv.Atoi(mm)
+	if err != nil {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// valid reports whether the recurrence has an interval of at least MinInterval, or a valid day and time of day.
+func (r Recurrence) valid() bool {
+	clock := r.hour >= 0 && r.hour < 24 && r.minute >= 0 && r.minute < 60
+	switch r.kind {
+	case recurEvery:
+		return r.interval >= MinInterval
+	case recurDaily:
+		return clock
+	case recurMonthly:
+		return clock && r.day >= 1 && r.day <= 31
+	default:
+		return false
+	}
+}
+
+// String returns the recurrence in the form accepted by ParseRecurrence.
+func (r Recurrence) String() string {
+	switch r.kind {
+	case recurEvery:
+		return "every " + r.interval.String()
+	case recurDaily:
+		return fmt.Sprintf("daily %02d:%02d", r.hour, r.minute)
+	case recurMonthly:
+		return fmt.Sprintf("monthly %d %02d:%02d", r.day, r.hour, r.minute)
+	default:
+		return "never"
+	}
+}
+
+// MarshalText encodes the recurrence in the form accepted by ParseRecurrence,
+// so that schedules can be saved by a RecurringStore.
+func (r Recurrence) MarshalText() ([]byte, error) {
+	if !r.valid() {
+		return nil, ErrRecurrenceInvalid
+	}
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes a recurrence encoded by MarshalText.
+func (r *Recurrence) UnmarshalText(text []byte) error {
+	parsed, err := ParseRecurrence(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// next returns the first occurrence at or after notBefore of a schedule started at start.
+// Occurrences are never before start.
+func (r Recurrence) next(start, notBefore time.Time) time.Time {
+	if notBefore.Before(start) {
+		notBefore = start
+	}
+
+	switch r.kind {
+	case recurEvery:
+		elapsed := notBefore.Sub(start)
+		steps := (elapsed + r.interval - 1) / r.interval
+		return start.Add(steps * r.interval)
+	case recurDaily:
+		t := notBefore.In(start.Location())
+		at := time.Date(t.Year(), t.Month(), t.Day(), r.hour, r.minute, 0, 0, t.Location())
+		if at.Before(t) {
+			at = time.Date(t.Year(), t.Month(), t.Day()+1, r.hour, r.minute, 0, 0, t.Location())
+		}
+		return at
+	default:
+		t := notBefore.In(start.Location())
+		at := r.inMonth(t.Year(), t.Month(), t.Location())
+		if at.Before(t) {
+			at = r.inMonth(t.Year(), t.Month()+1, t.Location())
+		}
+		return at
+	}
+}
+
+// inMonth returns the occurrence of a monthly recurrence in the given month,
+// on its last day if the month is shorter than the day of the recurrence.
+func (r Recurrence) inMonth(year int, month time.Month, loc *time.Location) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	return time.Date(year, month, min(r.day, last), r.hour, r.minute, 0, 0, loc)
+}
+
+// latest returns the last occurrence at or before notAfter of a schedule started at start,
+// or the zero time if there is none.
+func (r Recurrence) latest(start, notAfter time.Time) time.Time {
+	if notAfter.Before(start) {
+		return time.Time{}
+	}
+
+	var at time.Time
+	switch r.kind {
+	case recurEvery:
+		at = start.Add(notAfter.Sub(start) / r.interval * r.interval)
+	case recurDaily:
+		t := notAfter.In(start.Location())
+		at = time.Date(t.Year(), t.Month(), t.Day(), r.hour, r.minute, 0, 0, t.Location())
+		if at.After(t) {
+			at = time.Date(t.Year(), t.Month(), t.Day()-1, r.hour, r.minute, 0, 0, t.Location())
+		}
+	default:
+		t := notAfter.In(start.Location())
+		at = r.inMonth(t.Year(), t.Month(), t.Location())
+		if at.After(t) {
+			at = r.inMonth(t.Year(), t.Month()-1, t.Location())
+		}
+	}
+
+	if at.Before(start) {
+		return time.Time{}
+	}
+	return at
+}
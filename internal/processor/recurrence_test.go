@@ -0,0 +1,73 @@
+package processor_test
+
+import (
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("Recurrence", Label("unit"), func() {
+	DescribeTable("parsing a recurrence",
+		func(expr string, expected processor.Recurrence) {
+			recurrence, err := processor.ParseRecurrence(expr)
+			Expect(err).NotTo(HaveOccurred(), "parsing a valid expression should not return an error")
+			Expect(recurrence).To(Equal(expected), "the expression should be parsed")
+			Expect(recurrence.String()).To(Equal(expr), "the recurrence should be formatted back into the expression")
+		},
+		Entry("an interval", "every 15m0s", processor.Every(15*time.Minute)),
+		Entry("a time of day", "daily 09:30", processor.Daily(9, 30)),
+		Entry("a day of the month", "monthly 31 23:05", processor.Monthly(31, 23, 5)),
+	)
+
+	DescribeTable("parsing an invalid recurrence",
+		func(expr string) {
+			_, err := processor.ParseRecurrence(expr)
+			Expect(err).To(MatchError(processor.ErrRecurrenceInvalid), "parsing should fail with ErrRecurrenceInvalid")
+		},
+		Entry("an unknown keyword", "weekly 09:30"),
+		Entry("a non-positive interval", "every 0s"),
+		Entry("an interval shorter than the minimum", "every 500ms"),
+		Entry("an hour out of range", "daily 24:00"),
+		Entry("a day out of range", "monthly 32 09:30"),
+		Entry("a missing time of day", "monthly 1"),
+	)
+
+	DescribeTable("computing the first occurrence of a schedule",
+		func(recurrence processor.Recurrence, start, expected time.Time) {
+			now := time.Date(2028, time.February, 10, 12, 0, 0, 0, time.UTC)
+			ctrl := gomock.NewController(GinkgoT())
+			proc, err := processor.NewOrderProcessor(mock.NewMockUserStorage(ctrl), mock.NewMockWorkerPool(ctrl), processor.WithClock(fixedClock{now: now}))
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.CreateSchedule(processor.Schedule{ID: 1, Order: order.Order{UserID: 1, Amount: 10}, Recurrence: recurrence, Start: start})
+			Expect(err).NotTo(HaveOccurred(), "creating a schedule should not return an error")
+
+			schedules := proc.ListSchedules(1)
+			Expect(schedules).To(HaveLen(1), "the schedule should be listed")
+			Expect(schedules[0].Next).To(BeTemporally("==", expected), "the first occurrence should be due at the expected time")
+		},
+		Entry("of an interval, counted from the start",
+			processor.Every(100*time.Minute), time.Date(2028, time.February, 10, 0, 0, 0, 0, time.UTC), time.Date(2028, time.February, 10, 13, 20, 0, 0, time.UTC)),
+		Entry("of an interval starting later",
+			processor.Every(time.Hour), time.Date(2028, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, time.March, 1, 0, 0, 0, 0, time.UTC)),
+		Entry("of a time of day that has passed today",
+			processor.Daily(9, 30), time.Time{}, time.Date(2028, time.February, 11, 9, 30, 0, 0, time.UTC)),
+		Entry("of a day of the month beyond the end of the month",
+			processor.Monthly(31, 9, 30), time.Date(2028, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, time.February, 29, 9, 30, 0, 0, time.UTC)),
+	)
+
+	It("should derive the same order ID for the same occurrence", func() {
+		at := time.Date(2028, time.February, 10, 12, 0, 0, 0, time.UTC)
+
+		Expect(processor.OccurrenceID(1, at)).To(Equal(processor.OccurrenceID(1, at.In(time.FixedZone("CET", 3600)))), "the ID should not depend on the location")
+		Expect(processor.OccurrenceID(1, at)).To(BeNumerically(">", 0), "the ID should be positive")
+		Expect(processor.OccurrenceID(1, at)).NotTo(Equal(processor.OccurrenceID(2, at)), "schedules should get different IDs")
+		Expect(processor.OccurrenceID(1, at)).NotTo(Equal(processor.OccurrenceID(1, at.Add(time.Minute))), "occurrences should get different IDs")
+	})
+})
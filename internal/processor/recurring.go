@@ -0,0 +1,437 @@
+package processor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+)
+
+// maxCatchUp is the most occurrences of one schedule that are submitted at once,
+// e.g. the missed occurrences caught up with CatchUpAll.
+const maxCatchUp = 1000
+
+// CatchUpPolicy defines which occurrences of a Schedule that fell due while the processor
+// was down are submitted when a processor loads the schedule from a RecurringStore.
+type CatchUpPolicy int
+
+const (
+	// CatchUpSkip skips the missed occurrences; the schedule continues with the next one due.
+	CatchUpSkip CatchUpPolicy = iota
+	// CatchUpLatest submits only the most recent missed occurrence.
+	CatchUpLatest
+	// CatchUpAll submits every missed occurrence, oldest first, up to the latest 1000 of them.
+	// Older missed occurrences are skipped.
+	CatchUpAll
+)
+
+// valid reports whether the policy is one of the defined policies.
+func (p CatchUpPolicy) valid() bool {
+	return p >= CatchUpSkip && p <= CatchUpAll
+}
+
+// Schedule generates an order for a user at every occurrence of a Recurrence.
+type Schedule struct {
+	// ID identifies the schedule among the schedules of the processor. It must be positive.
+	ID int
+	// Order is the template of the generated orders. Its ID is replaced by the ID of
+	// each occurrence, see OccurrenceID.
+	Order order.Order
+	// Recurrence defines when the occurrences are due.
+	Recurrence Recurrence
+	// Start is the earliest time an occurrence may be due, and the time the intervals of Every
+	// are counted from. Daily and Monthly recur in its location. Defaults to the creation time.
+	// Occurrences due before the schedule is created are skipped.
+	Start time.Time
+	// CatchUp defines which missed occurrences are submitted after downtime. The default is CatchUpSkip.
+	CatchUp CatchUpPolicy
+}
+
+// ScheduleStatus is a snapshot of a Schedule, as listed by ListSchedules and saved by a RecurringStore.
+type ScheduleStatus struct {
+	// Schedule is the created schedule.
+	Schedule Schedule
+	// Paused reports whether the schedule has been paused with PauseSchedule.
+	Paused bool
+	// Next is the time of the next occurrence that has not been submitted yet.
+	Next time.Time
+}
+
+// RecurringStore keeps the schedules created with CreateSchedule across restarts of the processor,
+// so that occurrences missed during downtime can be caught up. It is saved after every change
+// to a schedule. Implementations must be safe for concurrent use.
+type RecurringStore interface {
+	// Load returns the schedules saved by the last call to Save.
+	Load() ([]ScheduleStatus, error)
+	// Save replaces the saved schedules with the given ones.
+	Save(schedules []ScheduleStatus) error
+}
+
+// NewMemoryRecurringStore creates a RecurringStore that keeps the saved schedules in memory,
+// e.g. to hand them from one processor to the next within the same process.
+func NewMemoryRecurringStore() RecurringStore {
+	return &memoryStore[ScheduleStatus]{}
+}
+
+// NewFileRecurringStore creates a RecurringStore that saves the schedules as JSON into the file at path.
+// The file is replaced atomically on every save. Loading a file that does not exist returns no schedules.
+func NewFileRecurringStore(path string) RecurringStore {
+	return &fileStore[ScheduleStatus]{path: path}
+}
+
+// OccurrenceID returns the order ID of the occurrence of the schedule due at the given time.
+// The same occurrence always gets the same ID, so that with deduplication enabled it is
+// applied once even if it is submitted again. Across a restart this requires a DedupStore
+// shared by both processors, see WithDedupStore. The ID is positive.
+func OccurrenceID(scheduleID int, at time.Time) int {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(scheduleID))
+	binary.BigEndian.PutUint64(buf[8:], uint64(at.UnixNano()))
+
+	h := fnv.New64a()
+	h.Write(buf[:])
+	if id := int(h.Sum64() & math.MaxInt64); id != 0 {
+		return id
+	}
+	return 1
+}
+
+// occurrence is an occurrence of a schedule that is due.
+type occurrence struct {
+	schedule Schedule
+	at       time.Time
+}
+
+// order returns the order generated for the occurrence.
+func (oc occurrence) order() order.Order {
+	ord := oc.schedule.Order
+	ord.ID = OccurrenceID(oc.schedule.ID, oc.at)
+	return ord
+}
+
+// recurringSchedules holds the created schedules and the next occurrence of each of them.
+type recurringSchedules struct {
+	mu        sync.Mutex
+	schedules map[int]*ScheduleStatus
+	wake      chan struct{}
+}
+
+func newRecurringSchedules() *recurringSchedules {
+	return &recurringSchedules{
+		schedules: make(map[int]*ScheduleStatus),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// add creates the schedule. Returns false if a schedule with the same ID exists.
+func (r *recurringSchedules) add(status ScheduleStatus) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.schedules[status.Schedule.ID]; exists {
+		return false
+	}
+	r.schedules[status.Schedule.ID] = &status
+	r.notify()
+	return true
+}
+
+// update applies fn to the schedule with the given ID. Returns false if there is no such schedule.
+func (r *recurringSchedules) update(id int, fn func(*ScheduleStatus)) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, exists := r.schedules[id]
+	if !exists {
+		return false
+	}
+	fn(status)
+	r.notify()
+	return true
+}
+
+// delete removes the schedule with the given ID. Returns false if there is no such schedule.
+func (r *recurringSchedules) delete(id int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.schedules[id]; !exists {
+		return false
+	}
+	delete(r.schedules, id)
+	return true
+}
+
+// list returns the schedules selected by keep, ordered by ID.
+func (r *recurringSchedules) list(keep func(ScheduleStatus) bool) []ScheduleStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var statuses []ScheduleStatus
+	for _, status := range r.schedules {
+		if keep(*status) {
+			statuses = append(statuses, *status)
+		}
+	}
+	slices.SortFunc(statuses, func(a, b ScheduleStatus) int {
+		return a.Schedule.ID - b.Schedule.ID
+	})
+	return statuses
+}
+
+// due returns the occurrences of the active schedules due at or before now, oldest first and
+// at most maxCatchUp per schedule, along with the time the next occurrence is due, or the zero
+// time if there is none. The occurrences stay due until they are advanced past.
+func (r *recurringSchedules) due(now time.Time) ([]occurrence, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []occurrence
+	var next time.Time
+	for _, status := range r.schedules {
+		if status.Paused {
+			continue
+		}
+
+		schedule := status.Schedule
+		at := status.Next
+		for n := 0; n < maxCatchUp && !at.After(now); n++ {
+			due = append(due, occurrence{schedule: schedule, at: at})
+			at = schedule.Recurrence.next(schedule.Start, at.Add(time.Nanosecond))
+		}
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+
+	slices.SortFunc(due, func(a, b occurrence) int {
+		return a.at.Compare(b.at)
+	})
+	return due, next
+}
+
+// advance moves the schedule past the occurrence, unless the schedule has been paused,
+// resumed or deleted since the occurrence was due.
+func (r *recurringSchedules) advance(oc occurrence) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, exists := r.schedules[oc.schedule.ID]
+	if !exists || status.Paused || !status.Next.Equal(oc.at) {
+		return
+	}
+	status.Next = oc.schedule.Recurrence.next(oc.schedule.Start, oc.at.Add(time.Nanosecond))
+}
+
+// rewind moves the schedule back to an occurrence that could not be submitted, so that it
+// is caught up once a processor runs the schedule again, unless the schedule has been
+// paused or deleted since, or has not moved past the occurrence.
+func (r *recurringSchedules) rewind(oc occurrence) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, exists := r.schedules[oc.schedule.ID]
+	if !exists || status.Paused || !oc.at.Before(status.Next) {
+		return
+	}
+	status.Next = oc.at
+}
+
+// notify wakes up the schedule loop. It must be called with mu held.
+func (r *recurringSchedules) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *orderProcessor) CreateSchedule(schedule Schedule) error {
+	now := o.clock.Now()
+	if schedule.Start.IsZero() {
+		schedule.Start = now
+	}
+	if schedule.ID <= 0 || !schedule.Recurrence.valid() || !schedule.CatchUp.valid() {
+		return ErrScheduleInvalid
+	}
+
+	schedule.Order.Currency = o.currencyOrDefault(schedule.Order.Currency)
+	first := occurrence{schedule: schedule, at: schedule.Recurrence.next(schedule.Start, now)}
+	if err := validate(first.order(), o.validators); err != nil {
+		return err
+	}
+
+	select {
+	case <-o.shutdownChan:
+		return ErrProcessorShutdown
+	default:
+	}
+
+	if !o.recurring.add(ScheduleStatus{Schedule: schedule, Next: first.at}) {
+		return ErrScheduleExists
+	}
+	o.saveSchedules()
+	return nil
+}
+
+func (o *orderProcessor) ListSchedules(userID int) []ScheduleStatus {
+	return o.recurring.list(func(status ScheduleStatus) bool {
+		return status.Schedule.Order.UserID == userID
+	})
+}
+
+func (o *orderProcessor) PauseSchedule(scheduleID int) error {
+	if !o.recurring.update(scheduleID, func(status *ScheduleStatus) {
+		status.Paused = true
+	}) {
+		return ErrScheduleNotFound
+	}
+	o.saveSchedules()
+	return nil
+}
+
+func (o *orderProcessor) ResumeSchedule(scheduleID int) error {
+	now := o.clock.Now()
+	if !o.recurring.update(scheduleID, func(status *ScheduleStatus) {
+		if status.Paused {
+			status.Paused = false
+			status.Next = status.Schedule.Recurrence.next(status.Schedule.Start, now)
+		}
+	}) {
+		return ErrScheduleNotFound
+	}
+	o.saveSchedules()
+	return nil
+}
+
+func (o *orderProcessor) DeleteSchedule(scheduleID int) error {
+	if !o.recurring.delete(scheduleID) {
+		return ErrScheduleNotFound
+	}
+	o.saveSchedules()
+	return nil
+}
+
+// saveSchedules saves all schedules into the recurring store, if one is set.
+// A failed save is logged; the schedules keep running.
+func (o *orderProcessor) saveSchedules() {
+	if o.recurringStore == nil {
+		return
+	}
+
+	o.recurringSaveMu.Lock()
+	defer o.recurringSaveMu.Unlock()
+
+	schedules := o.recurring.list(func(ScheduleStatus) bool { return true })
+	if err := o.recurringStore.Save(schedules); err != nil {
+		o.logger.Error("failed to save schedules", slog.Int("schedules", len(schedules)), slog.Any("error", err))
+	}
+}
+
+// loadSchedules creates the schedules saved in the recurring store, applying the catch-up
+// policy of each schedule to the occurrences that fell due while no processor ran it.
+func (o *orderProcessor) loadSchedules() error {
+	statuses, err := o.recurringStore.Load()
+	if err != nil {
+		return err
+	}
+
+	now := o.clock.Now()
+	for _, status := range statuses {
+		schedule := status.Schedule
+		if !status.Paused && !status.Next.After(now) {
+			switch schedule.CatchUp {
+			case CatchUpSkip:
+				status.Next = schedule.Recurrence.next(schedule.Start, now)
+			case CatchUpLatest:
+				if latest := schedule.Recurrence.latest(schedule.Start, now); latest.After(status.Next) {
+					status.Next = latest
+				}
+			case CatchUpAll:
+				if oldest := oldestCaughtUp(schedule, now); oldest.After(status.Next) {
+					status.Next = oldest
+				}
+			}
+		}
+		o.recurring.add(status)
+	}
+	o.saveSchedules()
+	return nil
+}
+
+// oldestCaughtUp returns the oldest of the latest maxCatchUp occurrences of the schedule
+// at or before now, or the zero time if there is none.
+func oldestCaughtUp(schedule Schedule, now time.Time) time.Time {
+	oldest := schedule.Recurrence.latest(schedule.Start, now)
+	for n := 1; n < maxCatchUp && !oldest.IsZero(); n++ {
+		previous := schedule.Recurrence.latest(schedule.Start, oldest.Add(-time.Nanosecond))
+		if previous.IsZero() {
+			break
+		}
+		oldest = previous
+	}
+	return oldest
+}
+
+// runSchedules submits the occurrences of the schedules as they become due, in the lane
+// of the user of each schedule. It runs until the processor is shut down and all lanes are done.
+// Occurrences that could not be submitted because of the shutdown are saved as still due.
+func (o *orderProcessor) runSchedules() {
+	lanes := newUserLanes()
+	defer func() {
+		lanes.wait()
+		o.saveSchedules()
+	}()
+
+	sleep := time.NewTimer(maxTimerSleep)
+	defer sleep.Stop()
+
+	for {
+		due, next := o.recurring.due(o.clock.Now())
+		for _, oc := range due {
+			o.recurring.advance(oc)
+			lanes.run(oc.schedule.Order.UserID, func() {
+				if err := o.submitOccurrence(oc); errors.Is(err, ErrProcessorShutdown) {
+					o.recurring.rewind(oc)
+				}
+			})
+		}
+		if len(due) > 0 {
+			o.saveSchedules()
+		}
+
+		wait := maxTimerSleep
+		if !next.IsZero() {
+			wait = max(min(wait, next.Sub(o.clock.Now())), 0)
+		}
+		sleep.Reset(wait)
+
+		select {
+		case <-o.shutdownChan:
+			return
+		case <-o.recurring.wake:
+		case <-sleep.C:
+		}
+	}
+}
+
+// submitOccurrence submits the order of a due occurrence. An order that is not accepted is logged.
+func (o *orderProcessor) submitOccurrence(oc occurrence) error {
+	ord := oc.order()
+	_, err := o.SubmitAsync(context.Background(), ord)
+	if err != nil {
+		o.logger.Warn("scheduled occurrence not submitted",
+			slog.Int("schedule_id", oc.schedule.ID),
+			slog.Int("order_id", ord.ID),
+			slog.Int("user_id", ord.UserID),
+			slog.Any("error", err),
+		)
+	}
+	return err
+}
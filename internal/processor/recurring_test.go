@@ -0,0 +1,181 @@
+package processor_test
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+// unsavedRecurringStore is a RecurringStore that loses every save,
+// like a processor that stops before its progress is saved.
+type unsavedRecurringStore struct {
+	processor.RecurringStore
+}
+
+func (unsavedRecurringStore) Save([]processor.ScheduleStatus) error {
+	return nil
+}
+
+var _ = Describe("Recurring schedules", Label("unit"), func() {
+	var proc processor.OrderProcessor
+
+	BeforeEach(func() {
+		ctrl := gomock.NewController(GinkgoT())
+		var err error
+		proc, err = processor.NewOrderProcessor(mock.NewMockUserStorage(ctrl), mock.NewMockWorkerPool(ctrl))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+	})
+
+	It("should list, pause, resume and delete schedules", func() {
+		schedule := processor.Schedule{ID: 1, Order: order.Order{UserID: 1, Amount: 10}, Recurrence: processor.Daily(9, 0)}
+		Expect(proc.CreateSchedule(schedule)).To(Succeed(), "creating a schedule should not return an error")
+		Expect(proc.CreateSchedule(schedule)).To(MatchError(processor.ErrScheduleExists), "creating a schedule with a used ID should fail")
+
+		Expect(proc.PauseSchedule(1)).To(Succeed(), "pausing a schedule should not return an error")
+		schedules := proc.ListSchedules(1)
+		Expect(schedules).To(HaveLen(1), "the schedule of the user should be listed")
+		Expect(schedules[0].Paused).To(BeTrue(), "the schedule should be paused")
+		Expect(schedules[0].Schedule.Order.Currency).To(Equal("USD"), "the template should be in the default currency")
+		Expect(proc.ListSchedules(2)).To(BeEmpty(), "schedules of other users should not be listed")
+
+		Expect(proc.ResumeSchedule(1)).To(Succeed(), "resuming a schedule should not return an error")
+		Expect(proc.ListSchedules(1)[0].Paused).To(BeFalse(), "the schedule should be resumed")
+
+		Expect(proc.DeleteSchedule(1)).To(Succeed(), "deleting a schedule should not return an error")
+		Expect(proc.ListSchedules(1)).To(BeEmpty(), "a deleted schedule should not be listed")
+		Expect(proc.DeleteSchedule(1)).To(MatchError(processor.ErrScheduleNotFound), "deleting a deleted schedule should fail")
+		Expect(proc.PauseSchedule(1)).To(MatchError(processor.ErrScheduleNotFound), "pausing a deleted schedule should fail")
+	})
+
+	It("should refuse invalid schedules", func() {
+		Expect(proc.CreateSchedule(processor.Schedule{Order: order.Order{UserID: 1, Amount: 10}, Recurrence: processor.Daily(9, 0)})).
+			To(MatchError(processor.ErrScheduleInvalid), "a schedule without an ID should be refused")
+		Expect(proc.CreateSchedule(processor.Schedule{ID: 1, Order: order.Order{UserID: 1, Amount: 10}})).
+			To(MatchError(processor.ErrScheduleInvalid), "a schedule without a recurrence should be refused")
+		Expect(proc.CreateSchedule(processor.Schedule{ID: 1, Order: order.Order{UserID: 1, Amount: 10}, Recurrence: processor.Every(time.Millisecond)})).
+			To(MatchError(processor.ErrScheduleInvalid), "a schedule recurring more often than MinInterval should be refused")
+		Expect(proc.CreateSchedule(processor.Schedule{ID: 1, Order: order.Order{UserID: 1}, Recurrence: processor.Daily(9, 0)})).
+			To(MatchError(processor.ErrOrderInvalid), "a schedule with an invalid template should be refused")
+	})
+})
+
+var _ = Describe("Recurring schedules E2E", Label("e2e"), func() {
+	It("should submit an order with a deterministic ID at every occurrence until paused", func() {
+		s := storage.NewStorage()
+		clock := &manualClock{now: time.Date(2028, time.February, 10, 12, 0, 0, 0, time.UTC)}
+		proc := newTestProcessor(s, processor.WithClock(clock))
+
+		start := clock.Now()
+		err := proc.CreateSchedule(processor.Schedule{ID: 7, Order: order.Order{UserID: 1, Amount: 10}, Recurrence: processor.Every(time.Minute), Start: start})
+		Expect(err).NotTo(HaveOccurred(), "creating a schedule should not return an error")
+
+		for i := 0; i < 3; i++ {
+			Eventually(balanceOf(s, 1)).WithTimeout(2*time.Second).Should(Equal(10*(i+1)), "an order should be applied at every occurrence")
+			status, ok := proc.GetOrderStatus(processor.OccurrenceID(7, start.Add(time.Duration(i)*time.Minute)))
+			Expect(ok).To(BeTrue(), "the order of every occurrence should be tracked by its occurrence ID")
+			Expect(status.Status).To(Equal(processor.StatusApplied), "the order of every occurrence should be applied")
+			clock.Advance(time.Minute)
+		}
+
+		Expect(proc.PauseSchedule(7)).To(Succeed(), "pausing a schedule should not return an error")
+		clock.Advance(time.Minute)
+		Consistently(balanceOf(s, 1), 1500*time.Millisecond).Should(Equal(30), "a paused schedule should stop submitting orders")
+	})
+
+	It("should submit the occurrences of other users while the queue of a user is full", func() {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		handler := processor.OrderHandlerFunc(func(_ context.Context, ord order.Order, s storage.Storage) (int, error) {
+			if ord.ID == 1 {
+				close(started)
+				<-release
+			}
+			return s.Add(ord.UserID, ord.Currency, ord.Amount)
+		})
+
+		s := storage.NewStorage()
+		proc := newTestProcessor(s, processor.WithHandler(handler), processor.WithQueueCapacity(1))
+
+		Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "submitting an order should not return an error")
+		Eventually(started).Should(BeClosed(), "a worker should start processing the order")
+		Expect(proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100})).To(Succeed(), "submitting to a queue with room should not return an error")
+
+		for id := 1; id <= 2; id++ {
+			err := proc.CreateSchedule(processor.Schedule{ID: id, Order: order.Order{UserID: id, Amount: 10}, Recurrence: processor.Every(time.Hour)})
+			Expect(err).NotTo(HaveOccurred(), "creating a schedule due now should not return an error")
+		}
+
+		Eventually(balanceOf(s, 2)).Should(Equal(10), "the occurrence of another user should be applied while the queue of the first is full")
+		close(release)
+		Eventually(balanceOf(s, 1)).Should(Equal(210), "the occurrence should be applied once its queue has room")
+	})
+
+	It("should catch up only the latest missed occurrences after a long downtime", func() {
+		clock := &manualClock{now: time.Date(2028, time.February, 10, 12, 0, 0, 0, time.UTC)}
+		start := clock.Now().Add(-2 * time.Hour)
+		store := processor.NewMemoryRecurringStore()
+		Expect(store.Save([]processor.ScheduleStatus{{
+			Schedule: processor.Schedule{ID: 1, Order: order.Order{UserID: 1, Amount: 1}, Recurrence: processor.Every(time.Second), Start: start, CatchUp: processor.CatchUpAll},
+			Next:     start,
+		}})).To(Succeed(), "saving the schedule should not fail")
+
+		s := storage.NewStorage()
+		newTestProcessor(s, processor.WithClock(clock), processor.WithRecurringStore(store))
+
+		Eventually(balanceOf(s, 1)).WithTimeout(5*time.Second).Should(Equal(1000), "only the latest 1000 missed occurrences should be caught up")
+		Consistently(balanceOf(s, 1), 100*time.Millisecond).Should(Equal(1000), "no more occurrences should be due")
+	})
+
+	It("should apply caught up occurrences once when the next processor catches them up again", func() {
+		start := time.Now().Add(-4*time.Minute - 30*time.Second)
+		store := processor.NewMemoryRecurringStore()
+		Expect(store.Save([]processor.ScheduleStatus{{
+			Schedule: processor.Schedule{ID: 1, Order: order.Order{UserID: 1, Amount: 10}, Recurrence: processor.Every(time.Minute), Start: start, CatchUp: processor.CatchUpAll},
+			Next:     start,
+		}})).To(Succeed(), "saving the schedule should not fail")
+		dedup := processor.NewMemoryDedupStore(0, &manualClock{now: time.Now()})
+
+		s := storage.NewStorage()
+		for range 2 {
+			proc := newTestProcessor(s, processor.WithRecurringStore(unsavedRecurringStore{store}), processor.WithDedupStore(dedup))
+			Eventually(balanceOf(s, 1)).Should(Equal(50), "the missed occurrences should be caught up")
+			Consistently(balanceOf(s, 1), 100*time.Millisecond).Should(Equal(50), "occurrences caught up again should be deduplicated")
+			proc.Shutdown()
+		}
+	})
+
+	DescribeTable("catching up occurrences missed while no processor ran the schedule",
+		func(policy processor.CatchUpPolicy, expected int) {
+			store := processor.NewFileRecurringStore(filepath.Join(GinkgoT().TempDir(), "schedules.json"))
+			start := time.Now().Add(-9*time.Minute - 30*time.Second)
+			Expect(store.Save([]processor.ScheduleStatus{{
+				Schedule: processor.Schedule{ID: 1, Order: order.Order{UserID: 1, Amount: 10}, Recurrence: processor.Every(time.Minute), Start: start, CatchUp: policy},
+				Next:     start,
+			}})).To(Succeed(), "saving the schedule should not fail")
+
+			s := storage.NewStorage()
+			proc := newTestProcessor(s, processor.WithRecurringStore(store))
+
+			Eventually(balanceOf(s, 1)).Should(Equal(expected), "the missed occurrences should be caught up according to the policy")
+			Consistently(balanceOf(s, 1), 100*time.Millisecond).Should(Equal(expected), "no more occurrences should be due")
+
+			schedules, err := store.Load()
+			Expect(err).NotTo(HaveOccurred(), "loading the store should not fail")
+			Expect(schedules).To(HaveLen(1), "the schedule should stay saved")
+			Expect(schedules[0].Next).To(BeTemporally("~", start.Add(10*time.Minute), time.Millisecond), "the next occurrence should be saved")
+			Expect(proc.ListSchedules(1)[0].Schedule.Recurrence).To(Equal(processor.Every(time.Minute)), "the recurrence should be loaded")
+		},
+		Entry("skipping them", processor.CatchUpSkip, 0),
+		Entry("submitting the latest one", processor.CatchUpLatest, 10),
+		Entry("submitting all of them", processor.CatchUpAll, 100),
+	)
+})
@@ -0,0 +1,118 @@
+package processor_test
+
+import (
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("Refunds", Label("unit"), func() {
+	It("should reject a refund without an original order", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		proc, err := processor.NewOrderProcessor(mock.NewMockUserStorage(ctrl), mock.NewMockWorkerPool(ctrl))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		err = proc.Submit(order.Refund{ID: 2, UserID: 1, Amount: 10}.Order())
+
+		var validationErr *processor.ValidationError
+		Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+		Expect(validationErr.Field).To(Equal("OriginalOrderID"), "violation should be on OriginalOrderID")
+	})
+})
+
+var _ = Describe("Refunds E2E", Label("e2e"), func() {
+	var (
+		s    storage.Storage
+		proc processor.OrderProcessor
+	)
+
+	BeforeEach(func() {
+		s = storage.NewStorage()
+		proc = newTestProcessor(s)
+	})
+
+	It("should refund a debit in parts but never more than was debited", func() {
+		outcomes := submitAll(proc,
+			order.Order{ID: 1, UserID: 1, Amount: 100},
+			order.Order{ID: 2, UserID: 1, Kind: order.KindDebit, Amount: 60},
+			order.Refund{ID: 3, UserID: 1, OriginalOrderID: 2, Amount: 40}.Order(),
+			order.Refund{ID: 4, UserID: 1, OriginalOrderID: 2, Amount: 30}.Order(),
+			order.Refund{ID: 5, UserID: 1, OriginalOrderID: 2}.Order(),
+		)
+
+		Expect(outcomes[2].Status).To(Equal(processor.StatusApplied), "a partial refund should be applied")
+		Expect(outcomes[2].Balance).To(Equal(80), "a refunded debit should credit the user")
+		Expect(outcomes[3].Err).To(MatchError(processor.ErrRefundExceeded), "refunding beyond the debited amount should fail")
+		Expect(outcomes[4].Status).To(Equal(processor.StatusApplied), "refunding the rest should be applied")
+		Expect(outcomes[4].Balance).To(Equal(100), "the whole debit should be refunded")
+
+		outcomes = submitAll(proc, order.Refund{ID: 6, UserID: 1, OriginalOrderID: 2}.Order())
+		Expect(outcomes[0].Err).To(MatchError(processor.ErrRefundExceeded), "a fully refunded order should not be refunded again")
+	})
+
+	It("should refund a credit by debiting the user", func() {
+		outcomes := submitAll(proc,
+			order.Order{ID: 1, UserID: 1, Amount: 100},
+			order.Refund{ID: 2, UserID: 1, OriginalOrderID: 1, Amount: 25}.Order(),
+		)
+
+		Expect(outcomes[1].Status).To(Equal(processor.StatusApplied), "refunding a credit should be applied")
+		balance, _ := s.Get(1, "USD")
+		Expect(balance).To(Equal(75), "a refunded credit should debit the user")
+	})
+
+	It("should only refund applied orders of the same user", func() {
+		outcomes := submitAll(proc,
+			order.Order{ID: 1, UserID: 1, Kind: order.KindDebit, Amount: 100},
+			order.Refund{ID: 2, UserID: 1, OriginalOrderID: 1}.Order(),
+			order.Order{ID: 3, UserID: 2, Amount: 100},
+			order.Refund{ID: 4, UserID: 1, OriginalOrderID: 3}.Order(),
+			order.Transfer{ID: 5, From: 2, To: 1, Amount: 10}.Order(),
+			order.Refund{ID: 6, UserID: 2, OriginalOrderID: 5}.Order(),
+		)
+
+		Expect(outcomes[0].Err).To(MatchError(processor.ErrInsufficientFunds), "the debit should fail")
+		Expect(outcomes[1].Err).To(MatchError(processor.ErrRefundOriginalNotFound), "a failed order should not be refundable")
+		Expect(outcomes[3].Err).To(MatchError(processor.ErrRefundOriginalNotFound), "an order of another user should not be refundable")
+		Expect(outcomes[5].Err).To(MatchError(processor.ErrRefundNotRefundable), "a transfer should not be refundable")
+	})
+
+	It("should not let a reorderable refund overtake its original order", func() {
+		refund := order.Refund{ID: 4, UserID: 1, OriginalOrderID: 2}.Order()
+		refund.Priority = order.PriorityHigh
+		refund.Reorderable = true
+
+		outcomes := submitAll(proc,
+			order.Order{ID: 1, UserID: 1, Amount: 100, Priority: order.PriorityLow},
+			order.Order{ID: 2, UserID: 1, Kind: order.KindDebit, Amount: 30, Priority: order.PriorityLow},
+			order.Order{ID: 3, UserID: 1, Amount: 5, Priority: order.PriorityLow},
+			refund,
+		)
+
+		Expect(outcomes[3].Status).To(Equal(processor.StatusApplied), "the refund should be applied after its original order")
+		Expect(outcomes[3].Balance).To(Equal(100), "the refund should still pass the orders behind its original order")
+	})
+
+	It("should forget the oldest applied orders beyond the refund retention", func() {
+		proc = newTestProcessor(s, processor.WithRefundRetention(2))
+
+		outcomes := submitAll(proc,
+			order.Order{ID: 1, UserID: 1, Amount: 100},
+			order.Order{ID: 2, UserID: 1, Amount: 10},
+			order.Order{ID: 3, UserID: 1, Amount: 10},
+			order.Refund{ID: 4, UserID: 1, OriginalOrderID: 1}.Order(),
+			order.Refund{ID: 5, UserID: 1, OriginalOrderID: 2}.Order(),
+		)
+
+		Expect(outcomes[3].Err).To(MatchError(processor.ErrRefundOriginalNotFound), "an order beyond the retention should no longer be refundable")
+		Expect(outcomes[4].Status).To(Equal(processor.StatusApplied), "an order within the retention should be refundable")
+		Expect(outcomes[4].Balance).To(Equal(110), "only the order within the retention should be refunded")
+	})
+})
@@ -0,0 +1,126 @@
+package processor
+
+import (
+	"slices"
+	"sync"
+)
+
+// defaultRetention is the default number of finished orders the processor remembers.
+const defaultRetention = 10000
+
+// orderRegistry tracks the futures of submitted orders by order ID and by user.
+// Transfers are tracked for both the sending and the receiving user.
+// Pending and in-flight orders are always tracked; finished orders are
+// remembered until more than retention orders have finished after them.
+// Orders with a zero ID are not tracked. If several orders share an ID,
+// lookups by ID return the latest one.
+type orderRegistry struct {
+	mu        sync.Mutex
+	futures   map[int]*OrderFuture
+	byUser    map[int]map[*OrderFuture]struct{}
+	finished  []*OrderFuture
+	retention int
+}
+
+func newOrderRegistry(retention int) *orderRegistry {
+	return &orderRegistry{
+		futures:   make(map[int]*OrderFuture),
+		byUser:    make(map[int]map[*OrderFuture]struct{}),
+		retention: retention,
+	}
+}
+
+// add starts tracking the future.
+func (r *orderRegistry) add(future *OrderFuture) {
+	ord := future.Order()
+	if ord.ID == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if previous, ok := r.futures[ord.ID]; ok && previous != future {
+		r.unindex(previous)
+	}
+	r.futures[ord.ID] = future
+
+	for _, userID := range parties(ord) {
+		userFutures, ok := r.byUser[userID]
+		if !ok {
+			userFutures = make(map[*OrderFuture]struct{})
+			r.byUser[userID] = userFutures
+		}
+		userFutures[future] = struct{}{}
+	}
+}
+
+// get returns the tracked future of the order with the given ID.
+func (r *orderRegistry) get(orderID int) (*OrderFuture, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	future, ok := r.futures[orderID]
+	return future, ok
+}
+
+// list returns the status of the tracked orders of a user selected by filter,
+// oldest submission first.
+func (r *orderRegistry) list(userID int, filter OrderFilter) []OrderStatus {
+	r.mu.Lock()
+	statuses := make([]OrderStatus, 0, len(r.byUser[userID]))
+	for future := range r.byUser[userID] {
+		statuses = append(statuses, future.Status())
+	}
+	r.mu.Unlock()
+
+	slices.SortStableFunc(statuses, func(a, b OrderStatus) int {
+		return a.History[0].At.Compare(b.History[0].At)
+	})
+
+	selected := statuses[:0]
+	for _, status := range statuses {
+		if filter.Limit > 0 && len(selected) == filter.Limit {
+			break
+		}
+		if filter.matches(status) {
+			selected = append(selected, status)
+		}
+	}
+	return selected
+}
+
+// finish records that the future has been resolved and forgets the oldest
+// finished orders beyond the retention limit.
+func (r *orderRegistry) finish(future *OrderFuture) {
+	if future.Order().ID == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finished = append(r.finished, future)
+	for len(r.finished) > r.retention {
+		r.unindex(r.finished[0])
+		r.finished[0] = nil
+		r.finished = r.finished[1:]
+	}
+}
+
+// unindex stops tracking the future. It must be called with mu held.
+func (r *orderRegistry) unindex(future *OrderFuture) {
+	ord := future.Order()
+	if tracked, ok := r.futures[ord.ID]; ok && tracked == future {
+		delete(r.futures, ord.ID)
+	}
+
+	for _, userID := range parties(ord) {
+		if userFutures, ok := r.byUser[userID]; ok {
+			delete(userFutures, future)
+			if len(userFutures) == 0 {
+				delete(r.byUser, userID)
+			}
+		}
+	}
+}
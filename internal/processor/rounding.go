@@ -0,0 +1,71 @@
+package processor
+
+import "math/big"
+
+// RoundingMode defines how a converted amount is rounded to the smallest unit of its currency.
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest unit, and ties to the even unit. It is the default.
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest unit, and ties away from zero.
+	RoundHalfUp
+	// RoundHalfDown rounds to the nearest unit, and ties toward zero.
+	RoundHalfDown
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundDown rounds toward zero, truncating the fraction.
+	RoundDown
+	// RoundCeiling rounds toward positive infinity.
+	RoundCeiling
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor
+)
+
+// valid reports whether the mode is one of the defined modes.
+func (m RoundingMode) valid() bool {
+	return m >= RoundHalfEven && m <= RoundFloor
+}
+
+// round rounds x to an integer according to the mode. It uses exact arithmetic,
+// so the same x is always rounded to the same integer.
+func (m RoundingMode) round(x *big.Rat) *big.Int {
+	num, den := x.Num(), x.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	// quo is truncated toward zero; away is the next integer away from zero.
+	sign := int64(x.Sign())
+	away := new(big.Int).Add(quo, big.NewInt(sign))
+
+	// cmp compares the discarded fraction with one half.
+	cmp := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den)
+
+	switch m {
+	case RoundHalfUp:
+		if cmp >= 0 {
+			return away
+		}
+	case RoundHalfDown:
+		if cmp > 0 {
+			return away
+		}
+	case RoundUp:
+		return away
+	case RoundCeiling:
+		if sign > 0 {
+			return away
+		}
+	case RoundFloor:
+		if sign < 0 {
+			return away
+		}
+	case RoundHalfEven:
+		if cmp > 0 || (cmp == 0 && quo.Bit(0) == 1) {
+			return away
+		}
+	}
+	return quo
+}
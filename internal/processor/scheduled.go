@@ -0,0 +1,500 @@
+package processor
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxTimerSleep is the longest time between two checks for due scheduled orders,
+// so that orders also become due when the clock of the processor jumps ahead.
+const maxTimerSleep = time.Second
+
+// ScheduleShutdownPolicy defines what Shutdown does with orders submitted with SubmitAt
+// that are not due yet.
+type ScheduleShutdownPolicy int
+
+const (
+	// ScheduleDrop makes Shutdown fail the scheduled orders with ErrProcessorShutdown.
+	ScheduleDrop ScheduleShutdownPolicy = iota
+	// ScheduleFlush makes Shutdown queue the scheduled orders right away, so that they are
+	// applied before Shutdown returns unless draining is disabled with WithDrainOnShutdown.
+	ScheduleFlush
+	// SchedulePersist makes Shutdown save the scheduled orders into the ScheduleStore set with
+	// WithScheduleStore and cancel them with ErrOrderPersisted. A processor created with the
+	// same store schedules them again.
+	SchedulePersist
+)
+
+// valid reports whether the policy is one of the defined policies.
+func (p ScheduleShutdownPolicy) valid() bool {
+	return p >= ScheduleDrop && p <= SchedulePersist
+}
+
+// ScheduledOrder is an order that is applied at or after a given time.
+type ScheduledOrder struct {
+	// Order is the scheduled order.
+	Order order.Order
+	// At is the time the order becomes due.
+	At time.Time
+}
+
+// ScheduleStore keeps the orders scheduled with SubmitAt across restarts of the processor.
+// A processor clears the store once it has scheduled the saved orders, so that each order
+// is scheduled again by one processor only. Implementations must be safe for concurrent use.
+type ScheduleStore interface {
+	// Load returns the orders saved by the last call to Save.
+	Load() ([]ScheduledOrder, error)
+	// Save replaces the saved orders with the given ones.
+	Save(orders []ScheduledOrder) error
+}
+
+// NewMemoryScheduleStore creates a ScheduleStore that keeps the saved orders in memory,
+// e.g. to hand them from one processor to the next within the same process.
+func NewMemoryScheduleStore() ScheduleStore {
+	return &memoryStore[ScheduledOrder]{}
+}
+
+// NewFileScheduleStore creates a ScheduleStore that saves the orders as JSON into the file at path.
+// The file is replaced atomically on every save. Loading a file that does not exist returns no orders.
+func NewFileScheduleStore(path string) ScheduleStore {
+	return &fileStore[ScheduledOrder]{path: path}
+}
+
+// memoryStore keeps the last saved list of items in memory.
+type memoryStore[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
+
+func (s *memoryStore[T]) Load() ([]T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]T(nil), s.items...), nil
+}
+
+func (s *memoryStore[T]) Save(items []T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = append([]T(nil), items...)
+	return nil
+}
+
+// fileStore keeps the last saved list of items as JSON in a file.
+type fileStore[T any] struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (s *fileStore[T]) Load() ([]T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *fileStore[T]) Save(items []T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// timer is an order waiting in the timer heap until it is due.
+type timer struct {
+	future      *OrderFuture
+	at          time.Time
+	cfg         submitConfig
+	deduplicate bool
+	seq         uint64
+	index       int
+}
+
+// timerHeap orders timers by due time, and timers due at the same time by submission.
+type timerHeap []*timer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return t
+}
+
+// timers holds the scheduled orders until they are due. Once closed, it accepts no more orders.
+type timers struct {
+	mu      sync.Mutex
+	heap    timerHeap
+	byOrder map[*OrderFuture]*timer
+	seq     uint64
+	closed  bool
+	wake    chan struct{}
+}
+
+func newTimers() *timers {
+	return &timers{
+		byOrder: make(map[*OrderFuture]*timer),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// add schedules the timer and wakes up the timer loop if the timer is the next one due.
+// Returns false if the timers are closed.
+func (t *timers) add(tm *timer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+
+	t.seq++
+	tm.seq = t.seq
+	heap.Push(&t.heap, tm)
+	t.byOrder[tm.future] = tm
+
+	if tm.index == 0 {
+		select {
+		case t.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// remove unschedules the order of the future. Returns false if it is not scheduled.
+func (t *timers) remove(future *OrderFuture) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tm, ok := t.byOrder[future]
+	if !ok {
+		return false
+	}
+	heap.Remove(&t.heap, tm.index)
+	delete(t.byOrder, future)
+	return true
+}
+
+// due unschedules and returns the timers due at or before now, earliest first,
+// along with the time the next timer is due, or the zero time if there is none.
+func (t *timers) due(now time.Time) ([]*timer, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var due []*timer
+	for len(t.heap) > 0 && !t.heap[0].at.After(now) {
+		tm := heap.Pop(&t.heap).(*timer)
+		delete(t.byOrder, tm.future)
+		due = append(due, tm)
+	}
+
+	if len(t.heap) == 0 {
+		return due, time.Time{}
+	}
+	return due, t.heap[0].at
+}
+
+// close stops accepting orders and returns the timers that are still scheduled, earliest first.
+func (t *timers) close() []*timer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	pending := make([]*timer, 0, len(t.heap))
+	for len(t.heap) > 0 {
+		pending = append(pending, heap.Pop(&t.heap).(*timer))
+	}
+	clear(t.byOrder)
+	return pending
+}
+
+func (o *orderProcessor) SubmitAt(ctx context.Context, ord order.Order, at time.Time, opts ...SubmitOption) (*OrderFuture, error) {
+	cfg := submitConfig{overflowPolicy: o.overflowPolicy, rateLimitPolicy: o.rateLimitPolicy}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ord.Currency = o.currencyOrDefault(ord.Currency)
+	future := newOrderFuture(ord, o.clock, o.observe)
+
+	switch {
+	case !cfg.overflowPolicy.valid():
+		return nil, o.reject(future, ErrOverflowPolicyInvalid)
+	case !cfg.rateLimitPolicy.valid():
+		return nil, o.reject(future, ErrRateLimitPolicyInvalid)
+	}
+
+	if err := validate(ord, o.validators); err != nil {
+		return nil, o.reject(future, err)
+	}
+
+	deduplicate := o.dedup != nil && ord.ID != 0
+	if deduplicate {
+		if original, duplicate := o.dedup.Reserve(future); duplicate {
+			return original, nil
+		}
+	}
+
+	if err := o.throttle(ctx, ord.UserID, cfg.rateLimitPolicy); err != nil {
+		return nil, o.fail(admitted{future: future, deduplicate: deduplicate}, err)
+	}
+
+	if err := o.scheduleAt(future, at, cfg, deduplicate); err != nil {
+		return nil, err
+	}
+	return future, nil
+}
+
+// scheduleAt puts an admitted order into the timer heap until it is due.
+// Returns ErrProcessorShutdown if the processor is shutting down.
+func (o *orderProcessor) scheduleAt(future *OrderFuture, at time.Time, cfg submitConfig, deduplicate bool) error {
+	o.registry.add(future)
+	future.transition(StatusScheduled)
+
+	if !o.timers.add(&timer{future: future, at: at, cfg: cfg, deduplicate: deduplicate}) {
+		return o.fail(admitted{future: future, deduplicate: deduplicate}, ErrProcessorShutdown)
+	}
+	return nil
+}
+
+// userLanes runs work in order for each user, on a goroutine per user with pending work,
+// so that work blocked for one user, e.g. on a full queue, does not hold up other users.
+type userLanes struct {
+	mu      sync.Mutex
+	pending map[int][]func()
+	wg      sync.WaitGroup
+}
+
+func newUserLanes() *userLanes {
+	return &userLanes{
+		pending: make(map[int][]func()),
+	}
+}
+
+// run queues work behind the work pending for the user.
+func (l *userLanes) run(userID int, work func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	queued, running := l.pending[userID]
+	l.pending[userID] = append(queued, work)
+	if !running {
+		l.wg.Go(func() {
+			l.drain(userID)
+		})
+	}
+}
+
+// drain runs the pending work of the user until there is none left.
+func (l *userLanes) drain(userID int) {
+	for {
+		l.mu.Lock()
+		queued := l.pending[userID]
+		if len(queued) == 0 {
+			delete(l.pending, userID)
+			l.mu.Unlock()
+			return
+		}
+		l.pending[userID] = queued[1:]
+		l.mu.Unlock()
+
+		queued[0]()
+	}
+}
+
+// wait blocks until all pending work has run. No work may be queued while waiting.
+func (l *userLanes) wait() {
+	l.wg.Wait()
+}
+
+// fire queues a scheduled order that is due according to its overflow policy,
+// in the lane of its user. An order that cannot be queued is rejected.
+func (o *orderProcessor) fire(tm *timer) {
+	o.timerLanes.run(tm.future.Order().UserID, func() {
+		o.queueDue(tm)
+	})
+}
+
+// queueDue queues a scheduled order that is due, rejecting it if it cannot be queued.
+func (o *orderProcessor) queueDue(tm *timer) {
+	if err := o.enqueue(context.Background(), tm.future, tm.cfg.overflowPolicy); err != nil {
+		o.fail(admitted{future: tm.future, deduplicate: tm.deduplicate}, err)
+	}
+}
+
+// discard resolves a scheduled order that never reached a queue. It holds no in-flight slot,
+// so its outcome is not reported to the observer.
+func (o *orderProcessor) discard(future *OrderFuture, status Status, err error) {
+	if future.finish(status, 0, err, false) {
+		o.registry.finish(future)
+	}
+}
+
+// runTimers queues the scheduled orders as they become due.
+// It runs until the timers are stopped by Shutdown.
+func (o *orderProcessor) runTimers() {
+	defer close(o.timersDone)
+
+	sleep := time.NewTimer(maxTimerSleep)
+	defer sleep.Stop()
+
+	for {
+		due, next := o.timers.due(o.clock.Now())
+		for _, tm := range due {
+			o.fire(tm)
+		}
+
+		wait := maxTimerSleep
+		if !next.IsZero() {
+			wait = max(min(wait, next.Sub(o.clock.Now())), 0)
+		}
+		sleep.Reset(wait)
+
+		select {
+		case <-o.timersStop:
+			return
+		case <-o.timers.wake:
+		case <-sleep.C:
+		}
+	}
+}
+
+// loadScheduled schedules the orders saved in the schedule store and then clears the store,
+// so that no other processor using it schedules them again. It is the last step of the
+// construction that can fail, so the orders stay in the store if the processor is not created.
+// Orders that are no longer valid are dropped and logged.
+func (o *orderProcessor) loadScheduled() error {
+	orders, err := o.scheduleStore.Load()
+	if err != nil {
+		return err
+	}
+
+	var reserved []*OrderFuture
+	cfg := submitConfig{overflowPolicy: o.overflowPolicy, rateLimitPolicy: o.rateLimitPolicy}
+	for _, scheduled := range orders {
+		scheduled.Order.Currency = o.currencyOrDefault(scheduled.Order.Currency)
+		future := newOrderFuture(scheduled.Order, o.clock, o.observe)
+		if err := validate(scheduled.Order, o.validators); err != nil {
+			o.logger.Warn("dropped invalid scheduled order",
+				slog.Int("order_id", scheduled.Order.ID),
+				slog.Int("user_id", scheduled.Order.UserID),
+				slog.Any("error", err),
+			)
+			continue
+		}
+
+		deduplicate := o.dedup != nil && scheduled.Order.ID != 0
+		if deduplicate {
+			if _, duplicate := o.dedup.Reserve(future); duplicate {
+				continue
+			}
+			reserved = append(reserved, future)
+		}
+		o.scheduleAt(future, scheduled.At, cfg, deduplicate)
+	}
+
+	if err := o.scheduleStore.Save(nil); err != nil {
+		for _, future := range reserved {
+			o.dedup.Release(future)
+		}
+		return err
+	}
+	return nil
+}
+
+// stopTimers stops the timer loop and settles the orders that are not due yet
+// according to the schedule shutdown policy. It returns once the orders that have
+// fallen due are queued.
+func (o *orderProcessor) stopTimers() {
+	close(o.timersStop)
+	<-o.timersDone
+	defer o.timerLanes.wait()
+
+	pending := o.timers.close()
+	switch o.schedulePolicy {
+	case ScheduleFlush:
+		for _, tm := range pending {
+			o.fire(tm)
+		}
+	case SchedulePersist:
+		orders := make([]ScheduledOrder, len(pending))
+		for i, tm := range pending {
+			orders[i] = ScheduledOrder{Order: tm.future.Order(), At: tm.at}
+		}
+		if err := o.scheduleStore.Save(orders); err != nil {
+			o.logger.Error("failed to persist scheduled orders", slog.Int("orders", len(orders)), slog.Any("error", err))
+			for _, tm := range pending {
+				o.discard(tm.future, StatusFailed, ErrProcessorShutdown)
+			}
+			return
+		}
+		for _, tm := range pending {
+			o.discard(tm.future, StatusCancelled, ErrOrderPersisted)
+		}
+	default:
+		for _, tm := range pending {
+			o.discard(tm.future, StatusFailed, ErrProcessorShutdown)
+		}
+	}
+}
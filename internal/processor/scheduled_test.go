@@ -0,0 +1,231 @@
+package processor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("Scheduled orders", Label("unit"), func() {
+	It("should hold a scheduled order until it is cancelled", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		proc, err := processor.NewOrderProcessor(mock.NewMockUserStorage(ctrl), mock.NewMockWorkerPool(ctrl))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		future, err := proc.SubmitAt(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100}, time.Now().Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+
+		status, ok := proc.GetOrderStatus(1)
+		Expect(ok).To(BeTrue(), "scheduled order should be tracked")
+		Expect(status.Status).To(Equal(processor.StatusScheduled), "order should wait in StatusScheduled")
+
+		Expect(proc.Cancel(1)).To(Succeed(), "cancelling a scheduled order should not return an error")
+
+		outcome, err := future.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for a resolved future should not return an error")
+		Expect(outcome.Err).To(MatchError(processor.ErrOrderCancelled), "outcome should carry ErrOrderCancelled")
+		Expect(historyStatuses(future.Status())).To(Equal([]processor.Status{
+			processor.StatusReceived, processor.StatusScheduled, processor.StatusCancelled,
+		}), "history should list every transition")
+		Expect(proc.Cancel(1)).To(MatchError(processor.ErrOrderFinished), "cancelling a cancelled order should return ErrOrderFinished")
+	})
+
+	It("should reject a scheduled order that fails validation", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		proc, err := processor.NewOrderProcessor(mock.NewMockUserStorage(ctrl), mock.NewMockWorkerPool(ctrl))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		future, err := proc.SubmitAt(context.Background(), order.Order{ID: 1, UserID: 1}, time.Now().Add(time.Hour))
+		Expect(err).To(MatchError(processor.ErrOrderInvalid), "an order without an amount should be rejected")
+		Expect(future).To(BeNil(), "no future should be returned for a rejected order")
+
+		status, _ := proc.GetOrderStatus(1)
+		Expect(status.Status).To(Equal(processor.StatusRejected), "order should be rejected")
+	})
+
+	It("should keep persisted orders in the store if the processor cannot be created", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		store := processor.NewMemoryScheduleStore()
+		persisted := []processor.ScheduledOrder{{Order: order.Order{ID: 1, UserID: 1, Amount: 100}, At: time.Now().Add(time.Hour)}}
+		Expect(store.Save(persisted)).To(Succeed(), "saving orders should not fail")
+
+		path := filepath.Join(GinkgoT().TempDir(), "schedules.json")
+		Expect(os.WriteFile(path, []byte("not json"), 0o600)).To(Succeed(), "writing the recurring store should not fail")
+
+		proc, err := processor.NewOrderProcessor(mock.NewMockUserStorage(ctrl), mock.NewMockWorkerPool(ctrl),
+			processor.WithScheduleStore(store),
+			processor.WithRecurringStore(processor.NewFileRecurringStore(path)),
+		)
+		Expect(proc).To(BeNil(), "processor should be nil when the recurring store cannot be loaded")
+		Expect(err).To(HaveOccurred(), "creating processor should return the load error")
+
+		orders, err := store.Load()
+		Expect(err).NotTo(HaveOccurred(), "loading the store should not fail")
+		Expect(orders).To(HaveLen(1), "the persisted order should stay in the store for the next processor")
+	})
+})
+
+var _ = Describe("Scheduled orders E2E", Label("e2e"), func() {
+	It("should apply scheduled orders when they are due, earliest first", func() {
+		proc := newTestProcessor(storage.NewStorage())
+
+		start := time.Now()
+		late, err := proc.SubmitAt(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100}, start.Add(100*time.Millisecond))
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+		early, err := proc.SubmitAt(context.Background(), order.Order{ID: 2, UserID: 1, Amount: 50}, start.Add(50*time.Millisecond))
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+		due, err := proc.SubmitAt(context.Background(), order.Order{ID: 3, UserID: 1, Amount: 10}, start.Add(-time.Minute))
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order that is due already should not return an error")
+
+		dueOutcome, err := due.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		earlyOutcome, err := early.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		lateOutcome, err := late.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+
+		Expect(dueOutcome.Status).To(Equal(processor.StatusApplied), "an order due already should be applied")
+		Expect(earlyOutcome.StartedAt).NotTo(BeTemporally("<", start.Add(50*time.Millisecond)), "an order should not start before it is due")
+		Expect(lateOutcome.StartedAt).NotTo(BeTemporally("<", start.Add(100*time.Millisecond)), "an order should not start before it is due")
+		Expect(lateOutcome.Balance).To(Equal(160), "all scheduled orders should be applied")
+		Expect(historyStatuses(late.Status())).To(Equal([]processor.Status{
+			processor.StatusReceived, processor.StatusScheduled, processor.StatusQueued, processor.StatusProcessing, processor.StatusApplied,
+		}), "history should list every transition")
+	})
+
+	It("should queue the due orders of other users while the queue of a user is full", func() {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		handler := processor.OrderHandlerFunc(func(_ context.Context, ord order.Order, s storage.Storage) (int, error) {
+			if ord.ID == 1 {
+				close(started)
+				<-release
+			}
+			return s.Add(ord.UserID, ord.Currency, ord.Amount)
+		})
+
+		proc := newTestProcessor(storage.NewStorage(), processor.WithHandler(handler), processor.WithQueueCapacity(1))
+
+		Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "submitting an order should not return an error")
+		Eventually(started).Should(BeClosed(), "a worker should start processing the order")
+		Expect(proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100})).To(Succeed(), "submitting to a queue with room should not return an error")
+
+		blocked, err := proc.SubmitAt(context.Background(), order.Order{ID: 3, UserID: 1, Amount: 100}, time.Now())
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+		other, err := proc.SubmitAt(context.Background(), order.Order{ID: 4, UserID: 2, Amount: 100}, time.Now())
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+
+		outcome, err := other.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "the order of another user should be applied while the queue of the first is full")
+		Expect(blocked.Status().Status).To(Equal(processor.StatusScheduled), "the order of the user with a full queue should wait for room")
+
+		close(release)
+		outcome, err = blocked.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "the order should be applied once its queue has room")
+	})
+
+	It("should fail scheduled orders that are not due on shutdown by default", func() {
+		proc := newTestProcessor(storage.NewStorage())
+
+		future, err := proc.SubmitAt(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100}, time.Now().Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+
+		proc.Shutdown()
+
+		outcome, err := future.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		Expect(outcome.Status).To(Equal(processor.StatusFailed), "the scheduled order should be dropped")
+		Expect(outcome.Err).To(MatchError(processor.ErrProcessorShutdown), "outcome should carry ErrProcessorShutdown")
+
+		_, err = proc.SubmitAt(context.Background(), order.Order{ID: 2, UserID: 1, Amount: 100}, time.Now().Add(time.Hour))
+		Expect(err).To(MatchError(processor.ErrProcessorShutdown), "scheduling after shutdown should fail")
+	})
+
+	It("should apply scheduled orders that are not due on shutdown when flushing", func() {
+		s := storage.NewStorage()
+		proc := newTestProcessor(s, processor.WithScheduleShutdown(processor.ScheduleFlush))
+
+		future, err := proc.SubmitAt(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100}, time.Now().Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+
+		proc.Shutdown()
+
+		outcome, err := future.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "the scheduled order should be applied before shutdown returns")
+		balance, _ := s.Get(1, "USD")
+		Expect(balance).To(Equal(100), "the balance should include the flushed order")
+	})
+
+	It("should schedule persisted orders again in the next processor", func() {
+		store := processor.NewFileScheduleStore(filepath.Join(GinkgoT().TempDir(), "schedule.json"))
+		s := storage.NewStorage()
+		first := newTestProcessor(s, processor.WithScheduleShutdown(processor.SchedulePersist), processor.WithScheduleStore(store))
+
+		at := time.Now().Add(200 * time.Millisecond)
+		future, err := first.SubmitAt(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100, Currency: "EUR"}, at)
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+
+		first.Shutdown()
+
+		outcome, err := future.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		Expect(outcome.Err).To(MatchError(processor.ErrOrderPersisted), "outcome should carry ErrOrderPersisted")
+
+		second := newTestProcessor(s, processor.WithScheduleShutdown(processor.SchedulePersist), processor.WithScheduleStore(store))
+		status, ok := second.GetOrderStatus(1)
+		Expect(ok).To(BeTrue(), "the persisted order should be tracked by the next processor")
+		Expect(status.Status).To(Equal(processor.StatusScheduled), "the persisted order should be scheduled again")
+
+		Eventually(func() int {
+			balance, _ := s.Get(1, "EUR")
+			return balance
+		}).Should(Equal(100), "the persisted order should be applied when it is due")
+		Expect(time.Now()).NotTo(BeTemporally("<", at), "the persisted order should keep its due time")
+
+		second.Shutdown()
+		orders, err := store.Load()
+		Expect(err).NotTo(HaveOccurred(), "loading the store should not fail")
+		Expect(orders).To(BeEmpty(), "applied orders should not be persisted again")
+	})
+
+	It("should schedule a persisted order again only once across several restarts", func() {
+		store := processor.NewMemoryScheduleStore()
+		s := storage.NewStorage()
+		first := newTestProcessor(s, processor.WithScheduleShutdown(processor.SchedulePersist), processor.WithScheduleStore(store))
+
+		_, err := first.SubmitAt(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100}, time.Now().Add(50*time.Millisecond))
+		Expect(err).NotTo(HaveOccurred(), "scheduling an order should not return an error")
+		first.Shutdown()
+
+		second := newTestProcessor(s, processor.WithScheduleStore(store))
+		Eventually(func() int {
+			balance, _ := s.Get(1, "USD")
+			return balance
+		}).Should(Equal(100), "the persisted order should be applied by the next processor")
+		second.Shutdown()
+
+		for range 2 {
+			restarted := newTestProcessor(s, processor.WithScheduleStore(store))
+			_, ok := restarted.GetOrderStatus(1)
+			Expect(ok).To(BeFalse(), "a later processor should not load the persisted order again")
+			restarted.Shutdown()
+		}
+
+		balance, _ := s.Get(1, "USD")
+		Expect(balance).To(Equal(100), "later restarts should not apply the persisted order again")
+	})
+
+})
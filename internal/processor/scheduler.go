@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+)
+
+// defaultOrdersPerTurn is how many orders of a user a worker applies by default
+// before it moves on to the next ready user.
+const defaultOrdersPerTurn = 16
+
+// lanes is the number of priorities, from order.PriorityLow to order.PriorityHigh.
+const lanes = int(order.PriorityHigh-order.PriorityLow) + 1
+
+// scheduler is the run queue of the user queues that have pending orders, with one
+// round-robin lane per priority. Tasks in the worker pool are interchangeable turns:
+// each takes the queue at the head of the highest non-empty lane, applies up to
+// ordersPerTurn of its orders and puts it back if it still has pending orders,
+// so a user with a long backlog cannot hold a worker while other users wait.
+// A queue runs in the lane of the highest priority among its pending orders,
+// so that the orders ahead of an urgent order of the same user are applied urgently too.
+type scheduler struct {
+	ordersPerTurn int
+
+	mu    sync.Mutex
+	lanes [lanes][]UserQueue
+	lane  map[UserQueue]int
+}
+
+func newScheduler(ordersPerTurn int) *scheduler {
+	return &scheduler{
+		ordersPerTurn: ordersPerTurn,
+		lane:          make(map[UserQueue]int),
+	}
+}
+
+// laneOf returns the index of the lane of the priority.
+func laneOf(priority order.Priority) int {
+	return min(max(int(priority-order.PriorityLow), 0), lanes-1)
+}
+
+// push appends the queue to the tail of the lane of its priority.
+func (s *scheduler) push(queue UserQueue) {
+	lane := laneOf(queue.Priority())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lanes[lane] = append(s.lanes[lane], queue)
+	s.lane[queue] = lane
+}
+
+// boost moves a ready queue to the tail of the lane of the priority if it is in a lower lane.
+// It has no effect on a queue that is not ready, which is pushed with its priority once its turn ends.
+func (s *scheduler) boost(queue UserQueue, priority order.Priority) {
+	lane := laneOf(priority)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.lane[queue]
+	if !ok || current >= lane {
+		return
+	}
+
+	s.lanes[current] = slices.DeleteFunc(s.lanes[current], func(ready UserQueue) bool { return ready == queue })
+	s.lanes[lane] = append(s.lanes[lane], queue)
+	s.lane[queue] = lane
+}
+
+// pop removes and returns the queue at the head of the highest non-empty lane.
+// Returns false if no queue is ready.
+func (s *scheduler) pop() (UserQueue, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for lane := lanes - 1; lane >= 0; lane-- {
+		if len(s.lanes[lane]) == 0 {
+			continue
+		}
+
+		queue := s.lanes[lane][0]
+		s.lanes[lane][0] = nil
+		s.lanes[lane] = s.lanes[lane][1:]
+		delete(s.lane, queue)
+		return queue, true
+	}
+	return nil, false
+}
+
+// remove takes the queue out of the run queue. Returns false if it is not there.
+func (s *scheduler) remove(queue UserQueue) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lane, ok := s.lane[queue]
+	if !ok {
+		return false
+	}
+
+	s.lanes[lane] = slices.DeleteFunc(s.lanes[lane], func(ready UserQueue) bool { return ready == queue })
+	delete(s.lane, queue)
+	return true
+}
@@ -0,0 +1,219 @@
+package processor_test
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
+)
+
+var _ = Describe("Fair scheduling E2E", Label("e2e"), func() {
+	It("should let a light user in after a turn of a heavy user", func() {
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var applied []int
+		handler := processor.OrderHandlerFunc(func(ctx context.Context, ord order.Order, s storage.Storage) (int, error) {
+			if ord.ID == 1 {
+				<-release
+			}
+			mu.Lock()
+			applied = append(applied, ord.UserID)
+			mu.Unlock()
+			return s.Add(ord.UserID, ord.Currency, ord.Amount)
+		})
+
+		pool, err := worker.NewWorkerPool(1, 10)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(storage.NewStorage(), pool,
+			processor.WithHandler(handler),
+			processor.WithQueueCapacity(100),
+			processor.WithOrdersPerTurn(4),
+		)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		heavy := make([]order.Order, 100)
+		for i := range heavy {
+			heavy[i] = order.Order{ID: i + 1, UserID: 1, Amount: 1}
+		}
+		for _, result := range proc.SubmitBatch(context.Background(), heavy) {
+			Expect(result.Err).NotTo(HaveOccurred(), "every order of the heavy user should be accepted")
+		}
+		Expect(proc.Submit(order.Order{ID: 1000, UserID: 2, Amount: 1})).To(Succeed(), "the order of the light user should be accepted")
+
+		close(release)
+		proc.Shutdown()
+
+		Expect(applied).To(HaveLen(101), "every order should be applied")
+		Expect(slices.Index(applied, 2)).To(Equal(4), "the light user should be served right after one turn of the heavy user")
+	})
+})
+
+var _ = Describe("Priority lanes E2E", Label("e2e"), func() {
+	var (
+		release chan struct{}
+		mu      sync.Mutex
+		applied []int
+		proc    processor.OrderProcessor
+	)
+
+	BeforeEach(func() {
+		release = make(chan struct{})
+		applied = nil
+		handler := processor.OrderHandlerFunc(func(ctx context.Context, ord order.Order, s storage.Storage) (int, error) {
+			if ord.ID == 1 {
+				<-release
+			}
+			mu.Lock()
+			applied = append(applied, ord.ID)
+			mu.Unlock()
+			return s.Add(ord.UserID, ord.Currency, ord.Amount)
+		})
+
+		pool, err := worker.NewWorkerPool(1, 10)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err = processor.NewOrderProcessor(storage.NewStorage(), pool,
+			processor.WithHandler(handler),
+			processor.WithOrdersPerTurn(4),
+		)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+	})
+
+	submit := func(orders ...order.Order) {
+		for _, result := range proc.SubmitBatch(context.Background(), orders) {
+			Expect(result.Err).NotTo(HaveOccurred(), "every order should be accepted")
+		}
+	}
+
+	// started waits until the only worker is busy with the first order.
+	started := func() {
+		Eventually(func() processor.Status {
+			status, _ := proc.GetOrderStatus(1)
+			return status.Status
+		}).Should(Equal(processor.StatusProcessing), "the worker should start the first order")
+	}
+
+	It("should serve users with orders of a higher priority first", func() {
+		var bulk []order.Order
+		for id := 1; id <= 8; id++ {
+			bulk = append(bulk, order.Order{ID: id, UserID: 1, Amount: 1, Priority: order.PriorityLow})
+		}
+		submit(bulk...)
+		started()
+		submit(order.Order{ID: 20, UserID: 2, Amount: 1})
+		submit(order.Order{ID: 30, UserID: 3, Amount: 1, Priority: order.PriorityHigh})
+
+		close(release)
+		proc.Shutdown()
+
+		Expect(applied).To(Equal([]int{1, 2, 3, 4, 30, 20, 5, 6, 7, 8}), "after a turn of the bulk user, users should be served by priority")
+	})
+
+	It("should boost a waiting user to the priority of its most urgent order without reordering it", func() {
+		submit(order.Order{ID: 1, UserID: 9, Amount: 1})
+		started()
+		submit(
+			order.Order{ID: 10, UserID: 1, Amount: 1, Priority: order.PriorityLow},
+			order.Order{ID: 11, UserID: 1, Amount: 1, Priority: order.PriorityLow},
+		)
+		submit(order.Order{ID: 20, UserID: 2, Amount: 1})
+		submit(order.Order{ID: 12, UserID: 1, Amount: 1, Priority: order.PriorityHigh})
+
+		future, err := proc.SubmitAsync(context.Background(), order.Order{ID: 13, UserID: 1, Amount: 1})
+		Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+
+		close(release)
+		proc.Shutdown()
+
+		Expect(applied).To(Equal([]int{1, 10, 11, 12, 13, 20}), "the boosted user should be served first, in submission order")
+		outcome, err := future.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		Expect(outcome.Status).To(Equal(processor.StatusApplied), "the orders of the boosted user should be applied")
+	})
+})
+
+// BenchmarkSkewedLoad measures how long orders of light users wait behind a few heavy users
+// that each submit a long backlog at once, with one worker per heavy user. Without a limit per
+// turn, light users wait until a heavy backlog is drained; with it, their latency stays bounded
+// by one turn of every ready user.
+func BenchmarkSkewedLoad(b *testing.B) {
+	const (
+		heavyUsers   = 4
+		heavyBacklog = 2000
+		lightUsers   = 50
+	)
+
+	for _, perTurn := range []int{heavyBacklog, 64, 16, 1} {
+		b.Run("orders_per_turn="+strconv.Itoa(perTurn), func(b *testing.B) {
+			var latencies []time.Duration
+			for range b.N {
+				pool, err := worker.NewWorkerPool(heavyUsers, heavyUsers+lightUsers)
+				if err != nil {
+					b.Fatal(err)
+				}
+				proc, err := processor.NewOrderProcessor(storage.NewStorage(), pool,
+					processor.WithHandler(processor.OrderHandlerFunc(spin)),
+					processor.WithQueueCapacity(heavyBacklog),
+					processor.WithOrdersPerTurn(perTurn),
+					processor.WithIdleTimeout(0),
+					processor.WithHoldTTL(0),
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var heavy []order.Order
+				for user := 1; user <= heavyUsers; user++ {
+					for range heavyBacklog {
+						heavy = append(heavy, order.Order{UserID: user, Amount: 1})
+					}
+				}
+				proc.SubmitBatch(context.Background(), heavy)
+
+				var futures []*processor.OrderFuture
+				for user := heavyUsers + 1; user <= heavyUsers+lightUsers; user++ {
+					future, err := proc.SubmitAsync(context.Background(), order.Order{UserID: user, Amount: 1})
+					if err != nil {
+						b.Fatal(err)
+					}
+					futures = append(futures, future)
+				}
+
+				for _, future := range futures {
+					outcome, _ := future.Wait(context.Background())
+					latencies = append(latencies, outcome.FinishedAt.Sub(outcome.SubmittedAt))
+				}
+				proc.Shutdown()
+			}
+
+			slices.Sort(latencies)
+			b.ReportMetric(float64(percentile(latencies, 50).Microseconds()), "p50-light-µs")
+			b.ReportMetric(float64(percentile(latencies, 99).Microseconds()), "p99-light-µs")
+		})
+	}
+}
+
+// spin applies the order after a few microseconds of busy work, standing in for a handler
+// whose cost is dominated by computation rather than waiting.
+func spin(_ context.Context, ord order.Order, s storage.Storage) (int, error) {
+	for start := time.Now(); time.Since(start) < 5*time.Microsecond; {
+	}
+	return s.Add(ord.UserID, ord.Currency, ord.Amount)
+}
+
+// percentile returns the p-th percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[min(len(sorted)-1, len(sorted)*p/100)]
+}
@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+)
+
+const (
+	// Day is the window of a daily spending limit.
+	Day = 24 * time.Hour
+	// Month is the window of a monthly spending limit.
+	Month = 30 * Day
+)
+
+// SpendingLimit caps the total amount a user may debit in one currency
+// over a rolling window ending at the time of each order.
+type SpendingLimit struct {
+	// Window is how far back debits count against the limit, e.g. Day or Month.
+	Window time.Duration
+	// Amount is the most that may be debited within the window.
+	Amount int
+	// Currency is the currency of the debits the limit applies to.
+	// An empty currency stands for the default currency.
+	Currency string
+}
+
+// valid reports whether the limit has a positive window and amount, and a valid currency.
+func (l SpendingLimit) valid() bool {
+	return l.Window > 0 && l.Amount > 0 && (l.Currency == "" || isCurrencyCode(l.Currency))
+}
+
+// spent is an applied debit that counts against the spending limits of its user.
+type spent struct {
+	at       time.Time
+	currency string
+	amount   int
+}
+
+// spendingTracker keeps the debits of each user within the longest window of the spending limits.
+// The orders of a user are checked and recorded sequentially by the task that applies them,
+// so a check cannot be raced by another order of the same user.
+type spendingTracker struct {
+	limits []SpendingLimit
+	window time.Duration
+	clock  Clock
+
+	mu    sync.Mutex
+	users map[int][]spent
+}
+
+// newSpendingTracker creates a tracker for the limits, resolving empty currencies to defaultCurrency.
+func newSpendingTracker(limits []SpendingLimit, defaultCurrency string, clock Clock) *spendingTracker {
+	t := &spendingTracker{
+		limits: make([]SpendingLimit, len(limits)),
+		clock:  clock,
+		users:  make(map[int][]spent),
+	}
+
+	for i, limit := range limits {
+		if limit.Currency == "" {
+			limit.Currency = defaultCurrency
+		}
+		t.limits[i] = limit
+		t.window = max(t.window, limit.Window)
+	}
+	return t
+}
+
+// spends reports whether the order takes money out of its user's balance.
+// An authorization counts when it is placed, since it takes the held amount out of
+// the balance, even if the hold is later voided or captured for less.
+// The debits that reverse refunded credits count too, see spendingHandler.
+func spends(ord order.Order) bool {
+	return ord.Kind == order.KindDebit || ord.Kind == order.KindTransfer || ord.Kind == order.KindAuthorize
+}
+
+// check returns a *LimitExceededError if the order would take the debits of its user
+// beyond one of the limits. When several limits would be exceeded, the one with the
+// least remaining allowance is reported.
+func (t *spendingTracker) check(ord order.Order) error {
+	if !spends(ord) {
+		return nil
+	}
+
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var exceeded *LimitExceededError
+	for _, limit := range t.limits {
+		if limit.Currency != ord.Currency {
+			continue
+		}
+
+		total := 0
+		for _, s := range t.users[ord.UserID] {
+			if s.currency == limit.Currency && now.Sub(s.at) < limit.Window {
+				total += s.amount
+			}
+		}
+
+		remaining := max(limit.Amount-total, 0)
+		if ord.Amount > remaining && (exceeded == nil || remaining < exceeded.Remaining) {
+			exceeded = &LimitExceededError{Limit: limit, Remaining: remaining}
+		}
+	}
+
+	if exceeded != nil {
+		return exceeded
+	}
+	return nil
+}
+
+// record counts the applied order against the limits of its user
+// and forgets the debits that have left the longest window.
+func (t *spendingTracker) record(ord order.Order) {
+	if !spends(ord) {
+		return
+	}
+
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	history := t.users[ord.UserID]
+	expired := 0
+	for expired < len(history) && now.Sub(history[expired].at) >= t.window {
+		expired++
+	}
+	t.users[ord.UserID] = append(history[expired:], spent{at: now, currency: ord.Currency, amount: ord.Amount})
+}
+
+// prune forgets the debits that have left the longest window,
+// and the users that have no debit left within it.
+func (t *spendingTracker) prune(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for userID, history := range t.users {
+		expired := 0
+		for expired < len(history) && now.Sub(history[expired].at) >= t.window {
+			expired++
+		}
+		if expired == len(history) {
+			delete(t.users, userID)
+			continue
+		}
+		t.users[userID] = history[expired:]
+	}
+}
+
+// spendingHandler checks the orders applied by the next handler against the spending limits
+// and records the ones that are applied. It is wrapped by ledgerHandler, so refunds are
+// checked as the credit or debit that reverses the original order.
+type spendingHandler struct {
+	next    OrderHandler
+	tracker *spendingTracker
+}
+
+func (h *spendingHandler) Handle(ctx context.Context, ord order.Order, storage storage.Storage) (int, error) {
+	if err := h.tracker.check(ord); err != nil {
+		balance, _ := storage.Get(ord.UserID, ord.Currency)
+		return balance, err
+	}
+
+	balance, err := h.next.Handle(ctx, ord, storage)
+	if err == nil {
+		h.tracker.record(ord)
+	}
+	return balance, err
+}
@@ -0,0 +1,114 @@
+package processor_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+)
+
+var _ = Describe("Spending limits E2E", Label("e2e"), func() {
+	var (
+		clock *manualClock
+		proc  processor.OrderProcessor
+	)
+
+	BeforeEach(func() {
+		clock = &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		proc = newTestProcessor(storage.NewStorage(),
+			processor.WithClock(clock),
+			processor.WithSpendingLimits(
+				processor.SpendingLimit{Window: processor.Day, Amount: 100},
+				processor.SpendingLimit{Window: processor.Month, Amount: 150},
+			),
+		)
+	})
+
+	It("should reject debits beyond the daily and monthly limits with the remaining allowance", func() {
+		Expect(apply(proc, order.Order{ID: 1, UserID: 1, Amount: 1000}).Status).To(Equal(processor.StatusApplied), "credits should not count against the limits")
+		Expect(apply(proc, order.Order{ID: 2, UserID: 1, Kind: order.KindDebit, Amount: 60}).Status).To(Equal(processor.StatusApplied), "a debit within the limits should be applied")
+
+		outcome := apply(proc, order.Order{ID: 3, UserID: 1, Kind: order.KindDebit, Amount: 50})
+		Expect(outcome.Status).To(Equal(processor.StatusFailed), "a debit beyond the daily limit should fail")
+		Expect(outcome.Err).To(MatchError(processor.ErrLimitExceeded), "the debit should fail with ErrLimitExceeded")
+
+		var exceeded *processor.LimitExceededError
+		Expect(errors.As(outcome.Err, &exceeded)).To(BeTrue(), "the outcome should carry a LimitExceededError")
+		Expect(exceeded.Limit.Window).To(Equal(processor.Day), "the daily limit should be reported")
+		Expect(exceeded.Remaining).To(Equal(40), "the remaining daily allowance should be reported")
+
+		clock.Advance(processor.Day)
+		Expect(apply(proc, order.Order{ID: 4, UserID: 1, Kind: order.KindTransfer, To: 2, Amount: 50}).Status).To(Equal(processor.StatusApplied), "the daily window should have rolled over")
+
+		outcome = apply(proc, order.Order{ID: 5, UserID: 1, Kind: order.KindDebit, Amount: 50})
+		Expect(errors.As(outcome.Err, &exceeded)).To(BeTrue(), "a debit beyond the monthly limit should fail with a LimitExceededError")
+		Expect(exceeded.Limit.Window).To(Equal(processor.Month), "the monthly limit should be reported")
+		Expect(exceeded.Remaining).To(Equal(40), "the remaining monthly allowance should be reported")
+
+		Expect(apply(proc, order.Order{ID: 6, UserID: 2, Kind: order.KindDebit, Amount: 50}).Status).To(Equal(processor.StatusApplied), "every user should have their own allowance")
+
+		balance, _ := proc.GetBalance(1, "")
+		Expect(balance).To(Equal(890), "only the debits within the limits should be applied")
+	})
+
+	It("should not count debits that fail against the limits", func() {
+		outcome := apply(proc, order.Order{ID: 1, UserID: 1, Kind: order.KindDebit, Amount: 100})
+		Expect(outcome.Err).To(MatchError(processor.ErrInsufficientFunds), "a debit without funds should fail")
+
+		apply(proc, order.Order{ID: 2, UserID: 1, Amount: 1000})
+		Expect(apply(proc, order.Order{ID: 3, UserID: 1, Kind: order.KindDebit, Amount: 100}).Status).To(Equal(processor.StatusApplied), "the failed debit should not use up the allowance")
+	})
+
+	It("should count authorizations and refunds of credits against the limits", func() {
+		apply(proc, order.Order{ID: 1, UserID: 1, Amount: 1000})
+		Expect(apply(proc, order.Order{ID: 2, UserID: 1, Kind: order.KindAuthorize, Amount: 60}).Status).To(Equal(processor.StatusApplied), "an authorization within the limits should be applied")
+		Expect(apply(proc, order.Order{ID: 3, UserID: 1, Kind: order.KindCapture, HoldID: 2}).Status).To(Equal(processor.StatusApplied), "capturing the hold should be applied")
+
+		outcome := apply(proc, order.Order{ID: 4, UserID: 1, Kind: order.KindAuthorize, Amount: 50})
+		Expect(outcome.Err).To(MatchError(processor.ErrLimitExceeded), "an authorization beyond the daily limit should fail with ErrLimitExceeded")
+
+		outcome = apply(proc, order.Order{ID: 5, UserID: 1, Kind: order.KindRefund, OriginalOrderID: 1, Amount: 50})
+		Expect(outcome.Err).To(MatchError(processor.ErrLimitExceeded), "a refund of a credit beyond the daily limit should fail with ErrLimitExceeded")
+		Expect(apply(proc, order.Order{ID: 6, UserID: 1, Kind: order.KindRefund, OriginalOrderID: 1, Amount: 40}).Status).To(Equal(processor.StatusApplied), "a refund of a credit within the limits should be applied")
+
+		outcome = apply(proc, order.Order{ID: 7, UserID: 1, Kind: order.KindDebit, Amount: 1})
+		Expect(outcome.Err).To(MatchError(processor.ErrLimitExceeded), "the authorization and the refund should have used up the daily allowance")
+
+		balance, _ := proc.GetBalance(1, "")
+		Expect(balance).To(Equal(900), "only the captured hold and the refund within the limits should be applied")
+	})
+
+	It("should never let concurrent debits of a user exceed the limit", func() {
+		apply(proc, order.Order{ID: 1, UserID: 1, Amount: 1000})
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var outcomes []processor.Outcome
+		for id := 2; id < 22; id++ {
+			wg.Go(func() {
+				defer GinkgoRecover()
+				outcome := apply(proc, order.Order{ID: id, UserID: 1, Kind: order.KindDebit, Amount: 10})
+				mu.Lock()
+				defer mu.Unlock()
+				outcomes = append(outcomes, outcome)
+			})
+		}
+		wg.Wait()
+
+		applied := 0
+		for _, outcome := range outcomes {
+			if outcome.Status == processor.StatusApplied {
+				applied++
+				continue
+			}
+			Expect(outcome.Err).To(MatchError(processor.ErrLimitExceeded), "debits beyond the limit should fail with ErrLimitExceeded")
+		}
+		Expect(applied).To(Equal(10), "exactly the debits within the daily limit should be applied")
+	})
+})
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"time"
+)
+
+// Status describes a state in the lifecycle of a submitted order.
+//
+// An order starts as StatusReceived and is either rejected before it is queued
+// (StatusRejected), or queued (StatusQueued). A queued order is either cancelled
+// (StatusCancelled) or taken by a worker (StatusProcessing), which either applies
+// it (StatusApplied) or fails it (StatusFailed). An order submitted with SubmitAt
+// waits in StatusScheduled until it is due, and is either cancelled or queued then.
+type Status int
+
+const (
+	// StatusReceived means the order has been submitted but not validated or queued yet.
+	StatusReceived Status = iota + 1
+	// StatusQueued means the order is waiting in its user's queue.
+	StatusQueued
+	// StatusProcessing means a worker is applying the order.
+	StatusProcessing
+	// StatusApplied means the order has been applied to the user's balance.
+	StatusApplied
+	// StatusFailed means the order could not be applied.
+	StatusFailed
+	// StatusCancelled means the order was cancelled before a worker started it.
+	StatusCancelled
+	// StatusRejected means the order was not queued, e.g. because it failed validation.
+	StatusRejected
+	// StatusScheduled means the order is waiting for the time it was scheduled at with SubmitAt.
+	StatusScheduled
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusReceived:
+		return "received"
+	case StatusQueued:
+		return "queued"
+	case StatusProcessing:
+		return "processing"
+	case StatusApplied:
+		return "applied"
+	case StatusFailed:
+		return "failed"
+	case StatusCancelled:
+		return "cancelled"
+	case StatusRejected:
+		return "rejected"
+	case StatusScheduled:
+		return "scheduled"
+	default:
+		return "unknown"
+	}
+}
+
+// Final reports whether the status ends the lifecycle of an order.
+func (s Status) Final() bool {
+	return s == StatusApplied || s == StatusFailed || s == StatusCancelled || s == StatusRejected
+}
+
+// StatusChange records the transition of an order to a status.
+type StatusChange struct {
+	// Status is the status the order transitioned to.
+	Status Status
+	// At is the time of the transition.
+	At time.Time
+}
+
+// OrderStatus is a snapshot of the lifecycle of a submitted order.
+type OrderStatus struct {
+	// Order is the submitted order.
+	Order order.Order
+	// Status is the current status of the order.
+	Status Status
+	// History lists every status the order went through, oldest first.
+	History []StatusChange
+	// Err is the reason the order failed or was rejected, or nil.
+	Err error
+}
+
+// OrderFilter selects the orders returned by ListOrders.
+// The zero value selects all tracked orders of the user.
+type OrderFilter struct {
+	// Statuses selects orders whose current status is one of the given statuses.
+	// An empty list selects orders in any status.
+	Statuses []Status
+	// Since selects orders submitted at or after the given time, if set.
+	Since time.Time
+	// Limit caps the number of returned orders, if greater than 0.
+	Limit int
+}
+
+// matches reports whether the order status is selected by the filter.
+func (f OrderFilter) matches(status OrderStatus) bool {
+	if !f.Since.IsZero() && status.History[0].At.Before(f.Since) {
+		return false
+	}
+
+	if len(f.Statuses) == 0 {
+		return true
+	}
+
+	for _, s := range f.Statuses {
+		if s == status.Status {
+			return true
+		}
+	}
+	return false
+}
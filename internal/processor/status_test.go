@@ -0,0 +1,142 @@
+package processor_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/internal/worker"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+// historyStatuses returns the statuses of a status history, oldest first.
+func historyStatuses(status processor.OrderStatus) []processor.Status {
+	statuses := make([]processor.Status, len(status.History))
+	for i, change := range status.History {
+		statuses[i] = change.Status
+	}
+	return statuses
+}
+
+var _ = Describe("Order status tracking", Label("unit"), func() {
+	When("querying the status of submitted orders", func() {
+		It("should report queued, cancelled and rejected orders with their history", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+			pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(1)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			Expect(proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100})).To(Succeed(), "submitting order should succeed")
+			Expect(proc.Submit(order.Order{ID: 2, UserID: 1, Amount: 100})).To(Succeed(), "submitting order should succeed")
+			Expect(proc.Submit(order.Order{ID: 3, UserID: 1, Amount: 0})).NotTo(Succeed(), "submitting invalid order should fail")
+			Expect(proc.Cancel(2)).To(Succeed(), "cancelling a pending order should succeed")
+
+			status, ok := proc.GetOrderStatus(1)
+			Expect(ok).To(BeTrue(), "queued order should be tracked")
+			Expect(status.Status).To(Equal(processor.StatusQueued), "order should be queued")
+			Expect(historyStatuses(status)).To(Equal([]processor.Status{processor.StatusReceived, processor.StatusQueued}), "history should list every transition")
+
+			status, ok = proc.GetOrderStatus(2)
+			Expect(ok).To(BeTrue(), "cancelled order should be tracked")
+			Expect(historyStatuses(status)).To(Equal([]processor.Status{processor.StatusReceived, processor.StatusQueued, processor.StatusCancelled}), "history should list every transition")
+
+			status, ok = proc.GetOrderStatus(3)
+			Expect(ok).To(BeTrue(), "rejected order should be tracked")
+			Expect(historyStatuses(status)).To(Equal([]processor.Status{processor.StatusReceived, processor.StatusRejected}), "history should list every transition")
+			Expect(status.Err).To(MatchError(processor.ErrOrderInvalid), "rejected order should carry the rejection reason")
+
+			_, ok = proc.GetOrderStatus(4)
+			Expect(ok).To(BeFalse(), "unknown order should not be tracked")
+		})
+
+		It("should list the orders of a user selected by filter", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+			pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(2)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			for id := 1; id <= 3; id++ {
+				Expect(proc.Submit(order.Order{ID: id, UserID: 1, Amount: 100})).To(Succeed(), "submitting order should succeed")
+			}
+			Expect(proc.Submit(order.Order{ID: 4, UserID: 2, Amount: 100})).To(Succeed(), "submitting order should succeed")
+			Expect(proc.Cancel(2)).To(Succeed(), "cancelling a pending order should succeed")
+
+			orders := proc.ListOrders(1, processor.OrderFilter{})
+			Expect(orders).To(HaveLen(3), "all orders of the user should be listed")
+			Expect(orders[0].Order.ID).To(Equal(1), "orders should be listed oldest first")
+
+			orders = proc.ListOrders(1, processor.OrderFilter{Statuses: []processor.Status{processor.StatusQueued}})
+			Expect(orders).To(HaveLen(2), "only queued orders should be listed")
+
+			orders = proc.ListOrders(1, processor.OrderFilter{Limit: 1})
+			Expect(orders).To(HaveLen(1), "limit should cap the number of listed orders")
+		})
+	})
+
+	When("more orders finish than the retention allows", func() {
+		It("should forget the oldest finished orders", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithRetention(1))
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			Expect(proc.Submit(order.Order{ID: 1, UserID: 0, Amount: 100})).NotTo(Succeed(), "submitting invalid order should fail")
+			Expect(proc.Submit(order.Order{ID: 2, UserID: 0, Amount: 100})).NotTo(Succeed(), "submitting invalid order should fail")
+
+			_, ok := proc.GetOrderStatus(1)
+			Expect(ok).To(BeFalse(), "oldest finished order should be forgotten")
+			_, ok = proc.GetOrderStatus(2)
+			Expect(ok).To(BeTrue(), "latest finished order should be remembered")
+		})
+
+		It("should return an error for a negative retention", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithRetention(-1))
+			Expect(proc).To(BeNil(), "processor should be nil when retention is invalid")
+			Expect(err).To(MatchError(processor.ErrRetentionInvalid), "creating processor should return ErrRetentionInvalid")
+		})
+	})
+})
+
+var _ = Describe("Order status tracking E2E", Label("e2e"), func() {
+	It("should record the processing and applied transitions", func() {
+		s := storage.NewStorage()
+		pool, err := worker.NewWorkerPool(1, 10)
+		Expect(err).NotTo(HaveOccurred(), "creating worker pool should not return an error")
+		proc, err := processor.NewOrderProcessor(s, pool, processor.WithHandler(processor.NewDelayedHandler(time.Millisecond)))
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+		defer proc.Shutdown()
+
+		future, err := proc.SubmitAsync(context.Background(), order.Order{ID: 1, UserID: 1, Amount: 100})
+		Expect(err).NotTo(HaveOccurred(), "submitting order should not return an error")
+		_, err = future.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the future should not return an error")
+
+		status, ok := proc.GetOrderStatus(1)
+		Expect(ok).To(BeTrue(), "applied order should be tracked")
+		Expect(status.Status).To(Equal(processor.StatusApplied), "order should be applied")
+		Expect(historyStatuses(status)).To(Equal([]processor.Status{
+			processor.StatusReceived, processor.StatusQueued, processor.StatusProcessing, processor.StatusApplied,
+		}), "history should list every transition")
+		for i := 1; i < len(status.History); i++ {
+			Expect(status.History[i].At).To(BeTemporally(">=", status.History[i-1].At), "transitions should be in chronological order")
+		}
+	})
+})
@@ -1,9 +1,9 @@
 package processor
 
 import (
+	"context"
 	"github.com/antoniuk-oleksandr/order_processor/internal/order"
 	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
-	"time"
 )
 
 type orderTask interface {
@@ -11,21 +11,125 @@ type orderTask interface {
 }
 
 type orderTaskStr struct {
-	queue   chan order.Order
-	storage storage.Storage
+	ctx       context.Context
+	queue     UserQueue
+	storage   storage.Storage
+	handler   OrderHandler
+	converter *converter
+	scheduler *scheduler
 }
 
-func NewOrderTask(queue chan order.Order, storage storage.Storage) orderTask {
+// NewOrderTask creates a task that applies the pending orders of the given queue to storage
+// using handler. The task returns once the queue is drained, releasing its worker to other users.
+// Queues parked on transfers that the task completes are drained by the same task.
+func NewOrderTask(queue UserQueue, storage storage.Storage, handler OrderHandler) orderTask {
 	return &orderTaskStr{
+		ctx:     context.Background(),
 		queue:   queue,
 		storage: storage,
+		handler: handler,
+	}
+}
+
+// newTurnTask creates a task that takes turns on the queues ready in scheduler until none is left.
+// Orders are settled into the account currency of their user with converter, if set.
+// The handler and the rate provider of converter are called with ctx.
+func newTurnTask(ctx context.Context, scheduler *scheduler, storage storage.Storage, handler OrderHandler, converter *converter) orderTask {
+	return &orderTaskStr{
+		ctx:       ctx,
+		storage:   storage,
+		handler:   handler,
+		converter: converter,
+		scheduler: scheduler,
 	}
 }
 
 func (o orderTaskStr) Process() {
-	for ord := range o.queue {
-		time.Sleep(time.Millisecond * 200)
+	if o.scheduler != nil {
+		o.takeTurns()
+		return
+	}
 
-		o.storage.Add(ord.UserID, ord.Amount)
+	queues := []UserQueue{o.queue}
+	for len(queues) > 0 {
+		queue := queues[0]
+		resumed, _ := o.drain(queue, 0)
+		queues = append(queues[1:], resumed...)
 	}
 }
+
+// takeTurns applies the orders of the queue at the head of the run queue for one turn,
+// puts the queue back at the tail if its turn ran out, and moves on until no queue is ready.
+// Queues parked on transfers completed during a turn are put at the tail too.
+func (o orderTaskStr) takeTurns() {
+	for {
+		queue, ok := o.scheduler.pop()
+		if !ok {
+			return
+		}
+
+		resumed, yielded := o.drain(queue, o.scheduler.ordersPerTurn)
+		for _, parked := range resumed {
+			o.scheduler.push(parked)
+		}
+		if yielded {
+			o.scheduler.push(queue)
+		}
+	}
+}
+
+// drain applies up to limit pending orders of the queue, or all of them if limit is 0, until
+// it is drained or parked on a transfer whose other leg has not been popped yet. Returns the
+// queues that were parked on the transfers completed on the way, which have to be resumed,
+// and yielded=true if the queue may still have pending orders because the limit was reached.
+func (o orderTaskStr) drain(queue UserQueue, limit int) (resumed []UserQueue, yielded bool) {
+	for n := 0; ; n++ {
+		if limit > 0 && n == limit {
+			return resumed, true
+		}
+
+		future, ok := queue.Pop()
+		if !ok {
+			return resumed, false
+		}
+
+		if future.Order().Kind == order.KindTransfer {
+			state, parked := future.arrive(queue)
+			if parked != nil {
+				resumed = append(resumed, parked)
+			}
+			if state == legWait {
+				return resumed, false
+			}
+			if state == legSkip {
+				continue
+			}
+		}
+
+		o.apply(future)
+	}
+}
+
+// apply applies a single order with the handler, converted into the account currency
+// of its user if needed, and resolves its future.
+func (o orderTaskStr) apply(future *OrderFuture) {
+	future.start()
+	ord := future.Order()
+	if o.converter != nil {
+		converted, conversion, err := o.converter.convert(o.ctx, ord)
+		if err != nil {
+			future.resolve(StatusFailed, 0, err)
+			return
+		}
+		ord = converted
+		future.convert(conversion)
+	}
+
+	balance, err := o.handler.Handle(o.ctx, ord, o.storage)
+	if err != nil {
+		future.resolve(StatusFailed, balance, err)
+		return
+	}
+
+	future.resolve(StatusApplied, balance, nil)
+}
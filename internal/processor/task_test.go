@@ -1,11 +1,16 @@
 package processor_test
 
 import (
+	"context"
+	"errors"
+
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
 
 	"github.com/antoniuk-oleksandr/order_processor/internal/order"
 	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
 	"github.com/antoniuk-oleksandr/order_processor/mock"
 )
 
@@ -14,23 +19,82 @@ var _ = Describe("Processor", Label("unit"), func() {
 		It("should call the storage Add method with correct parameters", func() {
 			chLength := 2
 			o := order.Order{
-				UserID: 1,
-				Amount: 100,
+				UserID:   1,
+				Amount:   100,
+				Currency: "USD",
 			}
 
 			ctrl := gomock.NewController(GinkgoT())
 			s := mock.NewMockUserStorage(ctrl)
 
-			s.EXPECT().Add(o.UserID, o.Amount).Times(chLength)
+			s.EXPECT().Add(o.UserID, o.Currency, o.Amount).Times(chLength)
 
-			orders := make(chan order.Order, chLength)
+			queue := processor.NewUserQueue(chLength, 0)
 			for range chLength {
-				orders <- o
+				queue.Push(processor.NewOrderFuture(o))
+			}
+
+			task := processor.NewOrderTask(queue, s, processor.NewDelayedHandler(0))
+			task.Process()
+		})
+
+		It("should resolve the order future with the post-apply balance", func() {
+			o := order.Order{
+				ID:       1,
+				UserID:   1,
+				Amount:   100,
+				Currency: "USD",
 			}
-			close(orders)
+			balance := 250
+
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			s.EXPECT().Add(o.UserID, o.Currency, o.Amount).Return(balance, nil).Times(1)
+
+			future := processor.NewOrderFuture(o)
+			queue := processor.NewUserQueue(1, 0)
+			queue.Push(future)
 
-			task := processor.NewOrderTask(orders, s)
+			task := processor.NewOrderTask(queue, s, processor.NewDelayedHandler(0))
 			task.Process()
+
+			Expect(future.Done()).To(BeClosed(), "future should be resolved once the task has processed the order")
+			outcome, err := future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for a resolved future should not return an error")
+			Expect(outcome.Order).To(Equal(o), "outcome should carry the submitted order")
+			Expect(outcome.Status).To(Equal(processor.StatusApplied), "order should be applied")
+			Expect(outcome.Balance).To(Equal(balance), "outcome should carry the post-apply balance")
+			Expect(outcome.Err).NotTo(HaveOccurred(), "applied order should not carry an error")
+			Expect(outcome.StartedAt).To(BeTemporally(">=", outcome.SubmittedAt), "order should start after it was submitted")
+			Expect(outcome.FinishedAt).To(BeTemporally(">=", outcome.StartedAt), "order should finish after it was started")
+		})
+
+		It("should resolve the order future as failed when the handler returns an error", func() {
+			o := order.Order{
+				ID:       1,
+				UserID:   1,
+				Amount:   100,
+				Currency: "USD",
+			}
+			handlerErr := errors.New("fraud check failed")
+
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			handler := processor.OrderHandlerFunc(func(_ context.Context, _ order.Order, _ storage.Storage) (int, error) {
+				return 0, handlerErr
+			})
+
+			future := processor.NewOrderFuture(o)
+			queue := processor.NewUserQueue(1, 0)
+			queue.Push(future)
+
+			task := processor.NewOrderTask(queue, s, handler)
+			task.Process()
+
+			outcome, err := future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for a resolved future should not return an error")
+			Expect(outcome.Status).To(Equal(processor.StatusFailed), "order should be failed")
+			Expect(outcome.Err).To(MatchError(handlerErr), "outcome should carry the handler error")
 		})
 	})
 })
@@ -0,0 +1,66 @@
+package processor
+
+import "github.com/antoniuk-oleksandr/order_processor/internal/order"
+
+// A transfer order is queued in the queues of both users, so that it is applied in order
+// with the other orders of either user. Each copy is a leg. The worker that pops the first leg
+// parks its queue, releasing the worker without waiting; the worker that pops the second leg
+// applies the transfer and resumes the parked queue. Both legs are pushed under userQueuesMu,
+// so every pair of queues holds its shared transfers in the same relative order and
+// parked queues can never wait for each other in a cycle.
+
+// leg tells a worker what to do with a transfer leg it has popped.
+type leg int
+
+const (
+	// legWait means the other leg has not been popped yet. The queue is parked until it is.
+	legWait leg = iota
+	// legRun means both legs have been popped. The worker applies the transfer.
+	legRun
+	// legSkip means the transfer has already been resolved, e.g. cancelled. The worker drops the leg.
+	legSkip
+)
+
+// parties returns the users whose balances the order changes.
+func parties(ord order.Order) []int {
+	if ord.Kind == order.KindTransfer {
+		return []int{ord.UserID, ord.To}
+	}
+	return []int{ord.UserID}
+}
+
+// arrive records that a transfer leg has been popped from queue and tells the worker what to do with it.
+// Alongside legRun and legSkip it returns the queue parked on the first leg, if any, which the worker
+// has to resume once it is done with the leg.
+func (f *OrderFuture) arrive(queue UserQueue) (leg, UserQueue) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.arrived++
+	parked := f.parked
+	f.parked = nil
+
+	switch {
+	case f.withdrawn || f.status.Final():
+		return legSkip, parked
+	case f.arrived == 1:
+		f.parked = queue
+		return legWait, nil
+	default:
+		return legRun, parked
+	}
+}
+
+// withdraw marks a transfer that none of the workers has started on as abandoned, so that its legs
+// are dropped once popped. Returns false if a leg has already been popped or the future is resolved.
+func (f *OrderFuture) withdraw() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.arrived > 0 || f.status.Final() {
+		return false
+	}
+
+	f.withdrawn = true
+	return true
+}
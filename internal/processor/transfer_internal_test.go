@@ -0,0 +1,53 @@
+package processor
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+)
+
+// racingQueue is a UserQueue on which a worker pops the next order while
+// the capacity of the queue is being checked.
+type racingQueue struct {
+	UserQueue
+	raced bool
+}
+
+func (q *racingQueue) race() {
+	if !q.raced {
+		q.raced = true
+		q.UserQueue.Pop()
+	}
+}
+
+func (q *racingQueue) Len() int {
+	n := q.UserQueue.Len()
+	q.race()
+	return n
+}
+
+func (q *racingQueue) Wait(limit int) <-chan struct{} {
+	q.race()
+	return q.UserQueue.Wait(limit)
+}
+
+var _ = Describe("Transfer legs", Label("unit"), func() {
+	It("should push both legs when a worker makes room during the capacity check", func() {
+		o := &orderProcessor{
+			userQueues:    make(map[int]UserQueue),
+			queueCapacity: 1,
+			clock:         systemClock{},
+			scheduler:     newScheduler(defaultOrdersPerTurn),
+		}
+		sender := &racingQueue{UserQueue: newUserQueue(1, 0, o.clock)}
+		sender.Push(NewOrderFuture(order.Order{ID: 1, UserID: 1, Amount: 100}))
+		o.userQueues[1] = sender
+
+		_, wait := o.pushTransfer(NewOrderFuture(order.Transfer{ID: 2, From: 1, To: 2, Amount: 10}.Order()), false)
+
+		Expect(wait).To(BeNil(), "the transfer should be pushed once the queue has room")
+		Expect(sender.Len()).To(Equal(1), "the sender should get its leg")
+		Expect(o.userQueues[2].Len()).To(Equal(1), "the recipient should get its leg")
+	})
+})
@@ -0,0 +1,141 @@
+package processor_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("Transfers", Label("unit"), func() {
+	var (
+		ctrl *gomock.Controller
+		s    *mock.MockUserStorage
+		pool *mock.MockWorkerPool
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		s = mock.NewMockUserStorage(ctrl)
+		pool = mock.NewMockWorkerPool(ctrl)
+	})
+
+	It("should queue a transfer for both users", func() {
+		pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(2)
+		proc, err := processor.NewOrderProcessor(s, pool)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		err = proc.Submit(order.Transfer{ID: 1, From: 1, To: 2, Amount: 100}.Order())
+		Expect(err).NotTo(HaveOccurred(), "submitting a transfer should not return an error")
+
+		Expect(proc.LiveQueues()).To(Equal(2), "the transfer should be queued for both users")
+		Expect(proc.ListOrders(1, processor.OrderFilter{})).To(HaveLen(1), "the transfer should be listed for the sender")
+		Expect(proc.ListOrders(2, processor.OrderFilter{})).To(HaveLen(1), "the transfer should be listed for the recipient")
+	})
+
+	It("should cancel a queued transfer", func() {
+		pool.EXPECT().AddTask(gomock.Any()).Return(nil).Times(2)
+		proc, err := processor.NewOrderProcessor(s, pool)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		Expect(proc.Submit(order.Transfer{ID: 1, From: 1, To: 2, Amount: 100}.Order())).To(Succeed(), "submitting a transfer should succeed")
+		Expect(proc.Cancel(1)).To(Succeed(), "cancelling a queued transfer should succeed")
+
+		status, ok := proc.GetOrderStatus(1)
+		Expect(ok).To(BeTrue(), "the cancelled transfer should be tracked")
+		Expect(status.Status).To(Equal(processor.StatusCancelled), "the transfer should be cancelled")
+		Expect(proc.ListOrders(2, processor.OrderFilter{Statuses: []processor.Status{processor.StatusQueued}})).To(BeEmpty(),
+			"the transfer should no longer be queued for the recipient")
+	})
+
+	It("should reject a transfer to the sending user", func() {
+		proc, err := processor.NewOrderProcessor(s, pool)
+		Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+		err = proc.Submit(order.Transfer{ID: 1, From: 1, To: 1, Amount: 100}.Order())
+
+		var validationErr *processor.ValidationError
+		Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+		Expect(validationErr.Field).To(Equal("To"), "violation should be on To")
+		Expect(validationErr.Rule).To(Equal(processor.RuleDistinct), "To should violate the distinct rule")
+	})
+})
+
+var _ = Describe("Transfers E2E", Label("e2e"), func() {
+	It("should apply a transfer in order with the other orders of both users", func() {
+		s := storage.NewStorage()
+		proc := newTestProcessor(s)
+
+		results := proc.SubmitBatch(context.Background(), []order.Order{
+			{ID: 1, UserID: 1, Amount: 100},
+			order.Transfer{ID: 2, From: 1, To: 2, Amount: 100}.Order(),
+			{ID: 3, UserID: 2, Kind: order.KindDebit, Amount: 100},
+		})
+		for _, result := range results {
+			Expect(result.Err).NotTo(HaveOccurred(), "every order should be accepted")
+			outcome, err := result.Future.Wait(context.Background())
+			Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+			Expect(outcome.Status).To(Equal(processor.StatusApplied), "order %d should be applied", outcome.Order.ID)
+		}
+
+		future, err := proc.SubmitAsync(context.Background(), order.Transfer{ID: 4, From: 1, To: 2, Amount: 1}.Order())
+		Expect(err).NotTo(HaveOccurred(), "submitting a transfer should not return an error")
+		outcome, err := future.Wait(context.Background())
+		Expect(err).NotTo(HaveOccurred(), "waiting for the outcome should not fail")
+		Expect(outcome.Err).To(MatchError(processor.ErrInsufficientFunds), "a transfer beyond the sender's balance should fail")
+		proc.Shutdown()
+
+		for userID := 1; userID <= 2; userID++ {
+			balance, _ := s.Get(userID, "USD")
+			Expect(balance).To(BeZero(), "user %d balance should be zero", userID)
+		}
+	})
+
+	It("should not deadlock on concurrent transfers in opposite directions", func() {
+		s := storage.NewStorage()
+		s.Add(1, "USD", 1000)
+		s.Add(2, "USD", 1000)
+		proc := newTestProcessor(s)
+
+		futures := make([]*processor.OrderFuture, 200)
+		var wg sync.WaitGroup
+		for i := range futures {
+			from, to := 1, 2
+			if i%2 == 1 {
+				from, to = 2, 1
+			}
+			wg.Go(func() {
+				defer GinkgoRecover()
+				future, err := proc.SubmitAsync(context.Background(), order.Transfer{From: from, To: to, Amount: 1}.Order())
+				Expect(err).NotTo(HaveOccurred(), "submitting a transfer should not return an error")
+				futures[i] = future
+			})
+		}
+		wg.Wait()
+
+		done := make(chan struct{})
+		go func() {
+			proc.Shutdown()
+			close(done)
+		}()
+		Eventually(done).WithTimeout(10*time.Second).Should(BeClosed(), "all transfers should complete")
+
+		for _, future := range futures {
+			Expect(future.Status().Status).To(Equal(processor.StatusApplied), "every transfer should be applied")
+		}
+
+		a, _ := s.Get(1, "USD")
+		b, _ := s.Get(2, "USD")
+		Expect(a+b).To(Equal(2000), "transfers should neither create nor destroy money")
+		Expect(a).To(Equal(1000), "transfers in both directions should cancel out")
+	})
+})
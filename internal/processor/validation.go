@@ -0,0 +1,234 @@
+package processor
+
+import (
+	"fmt"
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"strings"
+)
+
+const (
+	// RulePositive is violated by a field that must be greater than zero.
+	RulePositive = "positive"
+	// RuleNonZero is violated by a field that must not be zero.
+	RuleNonZero = "non_zero"
+	// RuleMax is violated by a field whose absolute value exceeds a maximum.
+	RuleMax = "max"
+	// RuleKnown is violated by a field that holds a value the processor does not know.
+	RuleKnown = "known"
+	// RuleDistinct is violated by a field that must differ from another field of the order.
+	RuleDistinct = "distinct"
+	// RuleFormat is violated by a field whose value is not in the expected format.
+	RuleFormat = "format"
+)
+
+// ValidationError describes a single validation rule violated by an order.
+type ValidationError struct {
+	// Field is the name of the order field that failed validation, e.g. "UserID".
+	Field string
+	// Rule is the name of the violated rule, e.g. RulePositive.
+	Rule string
+	// Message is a human-readable description of the violation.
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Is reports whether target is ErrOrderInvalid, so that errors.Is(err, ErrOrderInvalid)
+// holds for every validation error.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrOrderInvalid
+}
+
+// ValidationErrors lists all validation rules violated by an order.
+// It is returned by Submit when an order is rejected before being queued.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid order: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual validation errors.
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Validator checks an order before it is queued.
+type Validator interface {
+	// Validate returns nil if the order is valid, or a *ValidationError describing the violated rule.
+	Validate(ord order.Order) *ValidationError
+}
+
+// ValidatorFunc adapts an ordinary function to the Validator interface.
+type ValidatorFunc func(ord order.Order) *ValidationError
+
+// Validate calls f(ord).
+func (f ValidatorFunc) Validate(ord order.Order) *ValidationError {
+	return f(ord)
+}
+
+// PositiveUserID returns a Validator that rejects orders whose UserID is not greater than zero.
+func PositiveUserID() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		if ord.UserID > 0 {
+			return nil
+		}
+		return &ValidationError{Field: "UserID", Rule: RulePositive, Message: "must be greater than 0"}
+	})
+}
+
+// NonZeroAmount returns a Validator that rejects orders with a zero Amount,
+// except captures, voids and refunds, which default to the amount of their hold or original order.
+func NonZeroAmount() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		if ord.Amount != 0 || ord.Kind == order.KindCapture || ord.Kind == order.KindVoid || ord.Kind == order.KindRefund {
+			return nil
+		}
+		return &ValidationError{Field: "Amount", Rule: RuleNonZero, Message: "must not be 0"}
+	})
+}
+
+// KnownKind returns a Validator that rejects orders of a Kind not defined by the order package.
+func KnownKind() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		switch ord.Kind {
+		case order.KindCredit, order.KindDebit, order.KindTransfer,
+			order.KindAuthorize, order.KindCapture, order.KindVoid, order.KindRefund:
+			return nil
+		}
+		return &ValidationError{Field: "Kind", Rule: RuleKnown, Message: fmt.Sprintf("unknown kind %d", ord.Kind)}
+	})
+}
+
+// KnownPriority returns a Validator that rejects orders whose Priority is not one of the defined priorities.
+func KnownPriority() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		switch ord.Priority {
+		case order.PriorityLow, order.PriorityNormal, order.PriorityHigh:
+			return nil
+		}
+		return &ValidationError{Field: "Priority", Rule: RuleKnown, Message: fmt.Sprintf("unknown priority %d", ord.Priority)}
+	})
+}
+
+// PositiveDebit returns a Validator that rejects debit, transfer, authorize, capture and refund orders
+// whose Amount is negative, since such an order would move money in the opposite direction.
+func PositiveDebit() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		if ord.Amount >= 0 || ord.Kind == order.KindCredit || ord.Kind == order.KindVoid {
+			return nil
+		}
+		return &ValidationError{Field: "Amount", Rule: RulePositive, Message: fmt.Sprintf("must be greater than 0 for a %s", ord.Kind)}
+	})
+}
+
+// PositiveCredit returns a Validator that rejects credit orders whose Amount is negative.
+// Such a credit would take money from the user without the overdraft check of a debit order.
+func PositiveCredit() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		if ord.Amount >= 0 || ord.Kind != order.KindCredit {
+			return nil
+		}
+		return &ValidationError{Field: "Amount", Rule: RulePositive, Message: "must be greater than 0 for a credit, use a debit instead"}
+	})
+}
+
+// TransferRecipient returns a Validator that rejects transfer orders whose To user
+// is not greater than zero or is the sending user.
+func TransferRecipient() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		switch {
+		case ord.Kind != order.KindTransfer:
+			return nil
+		case ord.To <= 0:
+			return &ValidationError{Field: "To", Rule: RulePositive, Message: "must be greater than 0"}
+		case ord.To == ord.UserID:
+			return &ValidationError{Field: "To", Rule: RuleDistinct, Message: "must differ from UserID"}
+		default:
+			return nil
+		}
+	})
+}
+
+// HoldReference returns a Validator that rejects authorize orders without a positive ID,
+// which identifies their hold, and capture and void orders without a positive HoldID.
+func HoldReference() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		switch {
+		case ord.Kind == order.KindAuthorize && ord.ID <= 0:
+			return &ValidationError{Field: "ID", Rule: RulePositive, Message: "must be greater than 0 for an authorize"}
+		case (ord.Kind == order.KindCapture || ord.Kind == order.KindVoid) && ord.HoldID <= 0:
+			return &ValidationError{Field: "HoldID", Rule: RulePositive, Message: "must be greater than 0"}
+		default:
+			return nil
+		}
+	})
+}
+
+// RefundReference returns a Validator that rejects refund orders without a positive OriginalOrderID.
+func RefundReference() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		if ord.Kind != order.KindRefund || ord.OriginalOrderID > 0 {
+			return nil
+		}
+		return &ValidationError{Field: "OriginalOrderID", Rule: RulePositive, Message: "must be greater than 0"}
+	})
+}
+
+// CurrencyCode returns a Validator that rejects orders whose Currency is not
+// an ISO-4217 code of three upper-case letters.
+func CurrencyCode() Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		if isCurrencyCode(ord.Currency) {
+			return nil
+		}
+		return &ValidationError{Field: "Currency", Rule: RuleFormat, Message: fmt.Sprintf("%q is not an ISO-4217 code", ord.Currency)}
+	})
+}
+
+// isCurrencyCode reports whether code consists of three upper-case letters.
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// MaxAmount returns a Validator that rejects orders whose absolute Amount exceeds limit.
+func MaxAmount(limit int) Validator {
+	return ValidatorFunc(func(ord order.Order) *ValidationError {
+		if ord.Amount <= limit && ord.Amount >= -limit {
+			return nil
+		}
+		return &ValidationError{Field: "Amount", Rule: RuleMax, Message: fmt.Sprintf("must not exceed %d", limit)}
+	})
+}
+
+// validate runs all validators against the order and returns the violations, if any.
+func validate(ord order.Order, validators []Validator) error {
+	var errs ValidationErrors
+	for _, v := range validators {
+		if err := v.Validate(ord); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
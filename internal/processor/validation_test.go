@@ -0,0 +1,165 @@
+package processor_test
+
+import (
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/order"
+	"github.com/antoniuk-oleksandr/order_processor/internal/processor"
+	"github.com/antoniuk-oleksandr/order_processor/mock"
+)
+
+var _ = Describe("Order validation", Label("unit"), func() {
+	When("submitting an invalid order", func() {
+		It("should reject it with the failing fields and rules without queueing it", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 0, Amount: 0})
+			Expect(err).To(MatchError(processor.ErrOrderInvalid), "invalid order should match ErrOrderInvalid")
+
+			var validationErrs processor.ValidationErrors
+			Expect(errors.As(err, &validationErrs)).To(BeTrue(), "error should be ValidationErrors")
+			Expect(validationErrs).To(HaveLen(2), "both violated rules should be listed")
+			Expect(validationErrs[0].Field).To(Equal("UserID"), "first violation should be on UserID")
+			Expect(validationErrs[0].Rule).To(Equal(processor.RulePositive), "UserID should violate the positive rule")
+			Expect(validationErrs[1].Field).To(Equal("Amount"), "second violation should be on Amount")
+			Expect(validationErrs[1].Rule).To(Equal(processor.RuleNonZero), "Amount should violate the non-zero rule")
+
+			var validationErr *processor.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue(), "individual violations should be reachable with errors.As")
+			Expect(proc.LiveQueues()).To(BeZero(), "no queue should be created for a rejected order")
+		})
+
+		It("should reject an order exceeding the maximum amount", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithMaxAmount(1000))
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 1001})
+
+			var validationErr *processor.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+			Expect(validationErr.Field).To(Equal("Amount"), "violation should be on Amount")
+			Expect(validationErr.Rule).To(Equal(processor.RuleMax), "Amount should violate the max rule")
+		})
+
+		It("should reject a debit with a negative amount", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 1, Kind: order.KindDebit, Amount: -100})
+
+			var validationErr *processor.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+			Expect(validationErr.Field).To(Equal("Amount"), "violation should be on Amount")
+			Expect(validationErr.Rule).To(Equal(processor.RulePositive), "Amount should violate the positive rule")
+		})
+
+		It("should reject a credit with a negative amount", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 1, Amount: -1000})
+
+			var validationErr *processor.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+			Expect(validationErr.Field).To(Equal("Amount"), "violation should be on Amount")
+			Expect(validationErr.Rule).To(Equal(processor.RulePositive), "Amount should violate the positive rule")
+		})
+
+		It("should reject an order of an unknown kind", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 1, Kind: order.Kind(42), Amount: 100})
+
+			var validationErr *processor.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+			Expect(validationErr.Field).To(Equal("Kind"), "violation should be on Kind")
+			Expect(validationErr.Rule).To(Equal(processor.RuleKnown), "Kind should violate the known rule")
+		})
+
+		It("should reject an order of an unknown priority", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool)
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 1, Amount: 100, Priority: order.Priority(7)})
+
+			var validationErr *processor.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+			Expect(validationErr.Field).To(Equal("Priority"), "violation should be on Priority")
+			Expect(validationErr.Rule).To(Equal(processor.RuleKnown), "Priority should violate the known rule")
+		})
+
+		It("should run user-registered validators", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			blockedUser := processor.ValidatorFunc(func(ord order.Order) *processor.ValidationError {
+				if ord.UserID != 13 {
+					return nil
+				}
+				return &processor.ValidationError{Field: "UserID", Rule: "blocked", Message: "user is blocked"}
+			})
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithValidators(blockedUser))
+			Expect(err).NotTo(HaveOccurred(), "creating processor should not return an error")
+
+			err = proc.Submit(order.Order{ID: 1, UserID: 13, Amount: 100})
+
+			var validationErr *processor.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue(), "error should contain a ValidationError")
+			Expect(validationErr.Rule).To(Equal("blocked"), "custom rule should be reported")
+		})
+	})
+
+	When("creating a processor with invalid validation options", func() {
+		It("should return an error for a non-positive maximum amount", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithMaxAmount(0))
+			Expect(proc).To(BeNil(), "processor should be nil when max amount is invalid")
+			Expect(err).To(MatchError(processor.ErrMaxAmountInvalid), "creating processor should return ErrMaxAmountInvalid")
+		})
+
+		It("should return an error for a nil validator", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			s := mock.NewMockUserStorage(ctrl)
+			pool := mock.NewMockWorkerPool(ctrl)
+
+			proc, err := processor.NewOrderProcessor(s, pool, processor.WithValidators(nil))
+			Expect(proc).To(BeNil(), "processor should be nil when a validator is nil")
+			Expect(err).To(MatchError(processor.ErrValidatorInvalid), "creating processor should return ErrValidatorInvalid")
+		})
+	})
+})
@@ -1,15 +1,21 @@
 // Package storage provides thread-safe in-memory storage for user data.
 //
 // The storage package offers a simple key-value store that maps user IDs
-// to integer values. It is designed for concurrent access and uses read-write
-// locks to ensure data consistency across multiple goroutines.
+// and ISO-4217 currency codes to integer values. It is designed for concurrent
+// access and uses read-write locks to ensure data consistency across multiple goroutines.
+// Debits, transfers and holds check the user's overdraft limit and update the
+// values in a single critical section. All arithmetic on values is checked, and an
+// operation whose result would not fit into an int fails with an *OverflowError
+// without changing any value.
 //
 // Example usage:
 //
 //	store := storage.NewStorage()
-//	store.Add(123, 100)
-//	value, exists := store.Get(123)
+//	if _, err := store.Add(123, "EUR", 100); err != nil {
+//		log.Println("failed to add value:", err)
+//	}
+//	value, exists := store.Get(123, "EUR")
 //	if exists {
-//		fmt.Printf("User 123 has value: %d\n", value)
+//		fmt.Printf("User 123 has value: %d EUR\n", value)
 //	}
 package storage
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInsufficientFunds is returned when an operation would take a value below its negated overdraft limit.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrHoldExists is returned when placing a hold with an ID that is already in use.
+	ErrHoldExists = errors.New("hold already exists")
+	// ErrHoldNotFound is returned when capturing or releasing a hold that does not exist,
+	// has already been settled, or belongs to another user.
+	ErrHoldNotFound = errors.New("hold not found")
+	// ErrHoldExceeded is returned when capturing more than the held value.
+	ErrHoldExceeded = errors.New("capture exceeds held amount")
+	// ErrNegativeValue is returned when a negative value is passed to Debit, Transfer, Hold or Capture,
+	// which would move the value the other way around without the checks of that direction.
+	ErrNegativeValue = errors.New("value must not be negative")
+	// ErrCurrencyMismatch is matched by every CurrencyMismatchError.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrOverflow is matched by every OverflowError.
+	ErrOverflow = errors.New("value overflow")
+)
+
+// CurrencyMismatchError is returned when an operation refers to a value
+// in a currency other than the one the value is held in.
+type CurrencyMismatchError struct {
+	// Expected is the currency of the value.
+	Expected string
+	// Actual is the currency the operation was given.
+	Actual string
+}
+
+func (e *CurrencyMismatchError) Error() string {
+	return fmt.Sprintf("currency mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// Is reports whether target is ErrCurrencyMismatch, so that errors.Is(err, ErrCurrencyMismatch)
+// holds for every currency mismatch.
+func (e *CurrencyMismatchError) Is(target error) bool {
+	return target == ErrCurrencyMismatch
+}
+
+// OverflowError is returned when an operation would take a value beyond the range of an int.
+// The value is left unchanged.
+type OverflowError struct {
+	// Value is the value the operation started from.
+	Value int
+	// Op is the arithmetic operator that overflowed, "+" or "-".
+	Op string
+	// Operand is the amount the value was changed by.
+	Operand int
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("value overflow: %d %s %d does not fit into an int", e.Value, e.Op, e.Operand)
+}
+
+// Is reports whether target is ErrOverflow, so that errors.Is(err, ErrOverflow)
+// holds for every overflow.
+func (e *OverflowError) Is(target error) bool {
+	return target == ErrOverflow
+}
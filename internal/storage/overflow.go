@@ -0,0 +1,19 @@
+package storage
+
+// add returns a + b, or an *OverflowError if the sum does not fit into an int.
+func add(a, b int) (int, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return a, &OverflowError{Value: a, Op: "+", Operand: b}
+	}
+	return sum, nil
+}
+
+// sub returns a - b, or an *OverflowError if the difference does not fit into an int.
+func sub(a, b int) (int, error) {
+	diff := a - b
+	if (b > 0 && diff > a) || (b < 0 && diff < a) {
+		return a, &OverflowError{Value: a, Op: "-", Operand: b}
+	}
+	return diff, nil
+}
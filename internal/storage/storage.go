@@ -2,41 +2,311 @@ package storage
 
 import "sync"
 
+// Balance is the value of a user in one currency split into the available part
+// and the part reserved by holds.
+type Balance struct {
+	// Available is the value that can be spent.
+	Available int
+	// Held is the value reserved by holds that have not been captured or released yet.
+	Held int
+}
+
 // Storage provides thread-safe storage operations for user data.
-// It maps user IDs to integer values and supports concurrent access.
+// It maps each user ID and ISO-4217 currency code to a separate integer value
+// and supports concurrent access. Part of a value can be reserved by holds,
+// which take it out of the available value until they are captured or released.
 type Storage interface {
-	// Get retrieves the value associated with the given user ID.
+	// Get retrieves the available value associated with the given user ID in the given currency.
 	// Returns the value and true if found, or 0 and false if not found.
-	Get(ID int) (int, bool)
-	// Add increments the value for the given user ID by the specified amount.
+	Get(ID int, currency string) (int, bool)
+	// Balance retrieves both the available and the held value of the given user ID in the given currency.
+	// Returns the balance and true if found, or a zero balance and false if not found.
+	Balance(ID int, currency string) (Balance, bool)
+	// Balances retrieves the balances of the given user ID in every currency the user has a value in,
+	// keyed by currency code. Returns an empty map if the user is not found.
+	Balances(ID int) map[string]Balance
+	// Add increments the value for the given user ID in the given currency by the specified amount
+	// and returns the resulting value.
 	// If the ID doesn't exist, it will be created with the given value.
-	Add(ID int, value int)
+	// Returns the unchanged value and an *OverflowError if the result does not fit into an int.
+	Add(ID int, currency string, value int) (int, error)
+	// Debit decrements the value for the given user ID in the given currency by the specified amount,
+	// unless that would take it below the negated overdraft limit of the user.
+	// The check and the update happen atomically.
+	// Returns the resulting value, or the unchanged value and ErrInsufficientFunds,
+	// ErrNegativeValue if value is negative, or an *OverflowError if the result does not fit into an int.
+	Debit(ID int, currency string, value int) (int, error)
+	// Transfer decrements the value for the from user ID and increments the value for the to user ID
+	// in the given currency by the specified amount, unless that would take the from value below
+	// its negated overdraft limit. Both values change atomically, so readers never observe
+	// the amount in both or neither.
+	// Returns the resulting from value, or the unchanged from value and ErrInsufficientFunds,
+	// ErrNegativeValue if value is negative, or an *OverflowError if either result does not fit into an int.
+	Transfer(from int, to int, currency string, value int) (int, error)
+	// Hold moves the specified amount from the available value of the given user ID in the given currency
+	// to a new hold, unless that would take the available value below its negated overdraft limit.
+	// Returns the resulting available value, or the unchanged one and ErrInsufficientFunds,
+	// ErrHoldExists if holdID is already in use, ErrNegativeValue if value is negative,
+	// or an *OverflowError if either the available or the held value does not fit into an int.
+	Hold(ID int, currency string, holdID int, value int) (int, error)
+	// Capture settles the hold of the given user ID by spending the specified amount of it
+	// and returning the rest to the available value. A zero value captures the whole hold.
+	// Returns the resulting available value, or ErrHoldNotFound, ErrHoldExceeded,
+	// ErrNegativeValue if value is negative, a *CurrencyMismatchError if the hold is in another currency,
+	// or an *OverflowError if the available value does not fit into an int.
+	Capture(ID int, currency string, holdID int, value int) (int, error)
+	// Release returns the whole hold of the given user ID to the available value.
+	// Returns the resulting available value, or ErrHoldNotFound,
+	// a *CurrencyMismatchError if the hold is in another currency,
+	// or an *OverflowError if the available value does not fit into an int.
+	Release(ID int, currency string, holdID int) (int, error)
+	// SetOverdraftLimit sets how far below zero Debit, Transfer and Hold may take the available value
+	// for the given user ID in the given currency. Users without a limit cannot go below zero.
+	SetOverdraftLimit(ID int, currency string, limit int)
+}
+
+// account identifies the value of a user in one currency.
+type account struct {
+	userID   int
+	currency string
+}
+
+type hold struct {
+	account account
+	value   int
 }
 
 type storage struct {
-	data map[int]int
-	mu   sync.RWMutex
+	data       map[account]int
+	overdraft  map[account]int
+	held       map[account]int
+	holds      map[int]hold
+	currencies map[int][]string
+	mu         sync.RWMutex
 }
 
 // NewStorage creates a new Storage instance with an empty data map.
 // The returned storage is safe for concurrent use by multiple goroutines.
 func NewStorage() Storage {
 	return &storage{
-		data: make(map[int]int),
+		data:       make(map[account]int),
+		overdraft:  make(map[account]int),
+		held:       make(map[account]int),
+		holds:      make(map[int]hold),
+		currencies: make(map[int][]string),
+	}
+}
+
+func (k *storage) Add(ID int, currency string, value int) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	acc := account{userID: ID, currency: currency}
+	balance := k.data[acc]
+	result, err := add(balance, value)
+	if err != nil {
+		return balance, err
 	}
+
+	k.set(acc, result)
+	return result, nil
 }
 
-func (k *storage) Add(ID, value int) {
+func (k *storage) Debit(ID int, currency string, value int) (int, error) {
 	k.mu.Lock()
 	defer k.mu.Unlock()
 
-	k.data[ID] += value
+	acc := account{userID: ID, currency: currency}
+	balance := k.data[acc]
+	result, err := k.withdraw(acc, balance, value)
+	if err != nil {
+		return balance, err
+	}
+
+	k.set(acc, result)
+	return result, nil
 }
 
-func (k *storage) Get(ID int) (int, bool) {
+func (k *storage) Transfer(from, to int, currency string, value int) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	fromAcc := account{userID: from, currency: currency}
+	toAcc := account{userID: to, currency: currency}
+	balance := k.data[fromAcc]
+	result, err := k.withdraw(fromAcc, balance, value)
+	if err != nil {
+		return balance, err
+	}
+	received, err := add(k.data[toAcc], value)
+	if err != nil {
+		return balance, err
+	}
+
+	k.set(fromAcc, result)
+	k.set(toAcc, received)
+	return result, nil
+}
+
+func (k *storage) Hold(ID int, currency string, holdID, value int) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	acc := account{userID: ID, currency: currency}
+	balance := k.data[acc]
+	if _, exists := k.holds[holdID]; exists {
+		return balance, ErrHoldExists
+	}
+	result, err := k.withdraw(acc, balance, value)
+	if err != nil {
+		return balance, err
+	}
+	held, err := add(k.held[acc], value)
+	if err != nil {
+		return balance, err
+	}
+
+	k.set(acc, result)
+	k.held[acc] = held
+	k.holds[holdID] = hold{account: acc, value: value}
+	return result, nil
+}
+
+func (k *storage) Capture(ID int, currency string, holdID, value int) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	h, err := k.hold(ID, currency, holdID)
+	if err != nil {
+		return k.data[account{userID: ID, currency: currency}], err
+	}
+	if value < 0 {
+		return k.data[h.account], ErrNegativeValue
+	}
+	if value > h.value {
+		return k.data[h.account], ErrHoldExceeded
+	}
+	if value == 0 {
+		value = h.value
+	}
+	rest, err := sub(h.value, value)
+	if err != nil {
+		return k.data[h.account], err
+	}
+	result, err := add(k.data[h.account], rest)
+	if err != nil {
+		return k.data[h.account], err
+	}
+
+	k.settle(holdID, h)
+	k.data[h.account] = result
+	return result, nil
+}
+
+func (k *storage) Release(ID int, currency string, holdID int) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	h, err := k.hold(ID, currency, holdID)
+	if err != nil {
+		return k.data[account{userID: ID, currency: currency}], err
+	}
+
+	result, err := add(k.data[h.account], h.value)
+	if err != nil {
+		return k.data[h.account], err
+	}
+
+	k.settle(holdID, h)
+	k.data[h.account] = result
+	return result, nil
+}
+
+// withdraw returns the value of the account after taking value out of its balance.
+// Returns ErrNegativeValue if value is negative, ErrInsufficientFunds if the result would be
+// below the negated overdraft limit of the account, or an *OverflowError if it does not fit
+// into an int. It must be called with mu held.
+func (k *storage) withdraw(acc account, balance, value int) (int, error) {
+	if value < 0 {
+		return balance, ErrNegativeValue
+	}
+	result, err := sub(balance, value)
+	if err != nil {
+		return balance, err
+	}
+	if result < -k.overdraft[acc] {
+		return balance, ErrInsufficientFunds
+	}
+	return result, nil
+}
+
+// hold returns the hold of the user in the given currency. It must be called with mu held.
+func (k *storage) hold(ID int, currency string, holdID int) (hold, error) {
+	h, exists := k.holds[holdID]
+	if !exists || h.account.userID != ID {
+		return hold{}, ErrHoldNotFound
+	}
+	if h.account.currency != currency {
+		return hold{}, &CurrencyMismatchError{Expected: h.account.currency, Actual: currency}
+	}
+	return h, nil
+}
+
+// settle removes the hold and its value from the held value of its account. It must be called with mu held.
+func (k *storage) settle(holdID int, h hold) {
+	delete(k.holds, holdID)
+	k.held[h.account] -= h.value
+	if k.held[h.account] == 0 {
+		delete(k.held, h.account)
+	}
+}
+
+// set stores the value of the account, remembering the currency of a new account.
+// It must be called with mu held.
+func (k *storage) set(acc account, value int) {
+	if _, exists := k.data[acc]; !exists {
+		k.currencies[acc.userID] = append(k.currencies[acc.userID], acc.currency)
+	}
+	k.data[acc] = value
+}
+
+func (k *storage) SetOverdraftLimit(ID int, currency string, limit int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	acc := account{userID: ID, currency: currency}
+	if limit == 0 {
+		delete(k.overdraft, acc)
+		return
+	}
+	k.overdraft[acc] = limit
+}
+
+func (k *storage) Get(ID int, currency string) (int, bool) {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
-	val, ok := k.data[ID]
+	val, ok := k.data[account{userID: ID, currency: currency}]
 	return val, ok
 }
+
+func (k *storage) Balance(ID int, currency string) (Balance, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	acc := account{userID: ID, currency: currency}
+	available, ok := k.data[acc]
+	return Balance{Available: available, Held: k.held[acc]}, ok
+}
+
+func (k *storage) Balances(ID int) map[string]Balance {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	balances := make(map[string]Balance, len(k.currencies[ID]))
+	for _, currency := range k.currencies[ID] {
+		acc := account{userID: ID, currency: currency}
+		balances[currency] = Balance{Available: k.data[acc], Held: k.held[acc]}
+	}
+	return balances
+}
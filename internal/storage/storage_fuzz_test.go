@@ -0,0 +1,222 @@
+package storage_test
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/antoniuk-oleksandr/order_processor/internal/storage"
+)
+
+// fits reports whether the exact result of an operation is within the range of an int.
+func fits(result *big.Int) bool {
+	return result.Cmp(big.NewInt(math.MinInt)) >= 0 && result.Cmp(big.NewInt(math.MaxInt)) <= 0
+}
+
+// checkResult compares the outcome of an operation on a value that started at start
+// with its exact result, and fails the test unless the operation either applied the
+// exact result or returned an *OverflowError and kept the start value.
+func checkResult(t *testing.T, start int, exact *big.Int, result int, err error) {
+	t.Helper()
+
+	if !fits(exact) {
+		if !errors.Is(err, storage.ErrOverflow) {
+			t.Fatalf("expected ErrOverflow for exact result %s, got %d, %v", exact, result, err)
+		}
+		if result != start {
+			t.Fatalf("expected the value to stay %d on overflow, got %d", start, result)
+		}
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("expected exact result %s, got error %v", exact, err)
+	}
+	if big.NewInt(int64(result)).Cmp(exact) != 0 {
+		t.Fatalf("expected exact result %s, got %d", exact, result)
+	}
+}
+
+func FuzzAdd(f *testing.F) {
+	f.Add(int64(0), int64(100))
+	f.Add(int64(math.MaxInt64), int64(1))
+	f.Add(int64(math.MinInt64), int64(-1))
+	f.Add(int64(math.MaxInt64), int64(math.MinInt64))
+
+	f.Fuzz(func(t *testing.T, start, value int64) {
+		s := storage.NewStorage()
+		if _, err := s.Add(1, "USD", int(start)); err != nil {
+			t.Fatalf("expected the first add to succeed, got %v", err)
+		}
+
+		exact := new(big.Int).Add(big.NewInt(start), big.NewInt(value))
+		result, err := s.Add(1, "USD", int(value))
+		checkResult(t, int(start), exact, result, err)
+
+		balance, _ := s.Get(1, "USD")
+		if balance != result {
+			t.Fatalf("expected the stored value %d to match the result %d", balance, result)
+		}
+	})
+}
+
+func FuzzDebit(f *testing.F) {
+	f.Add(int64(100), int64(0), int64(40))
+	f.Add(int64(math.MinInt64), int64(math.MaxInt64), int64(1))
+	f.Add(int64(math.MaxInt64), int64(0), int64(-1))
+	f.Add(int64(0), int64(math.MaxInt64), int64(math.MaxInt64))
+
+	f.Fuzz(func(t *testing.T, start, limit, value int64) {
+		if limit < 0 {
+			limit = -(limit + 1)
+		}
+
+		s := storage.NewStorage()
+		s.SetOverdraftLimit(1, "USD", int(limit))
+		if _, err := s.Add(1, "USD", int(start)); err != nil {
+			t.Fatalf("expected the first add to succeed, got %v", err)
+		}
+
+		exact := new(big.Int).Sub(big.NewInt(start), big.NewInt(value))
+		result, err := s.Debit(1, "USD", int(value))
+		if value < 0 {
+			if !errors.Is(err, storage.ErrNegativeValue) || result != int(start) {
+				t.Fatalf("expected ErrNegativeValue and value %d, got %d, %v", start, result, err)
+			}
+			return
+		}
+		if fits(exact) && exact.Cmp(big.NewInt(-limit)) < 0 {
+			if !errors.Is(err, storage.ErrInsufficientFunds) || result != int(start) {
+				t.Fatalf("expected ErrInsufficientFunds and value %d, got %d, %v", start, result, err)
+			}
+			return
+		}
+		checkResult(t, int(start), exact, result, err)
+	})
+}
+
+func FuzzTransfer(f *testing.F) {
+	f.Add(int64(100), int64(0), int64(30))
+	f.Add(int64(100), int64(math.MaxInt64), int64(1))
+	f.Add(int64(math.MaxInt64), int64(math.MinInt64), int64(-1))
+
+	f.Fuzz(func(t *testing.T, from, to, value int64) {
+		s := storage.NewStorage()
+		s.SetOverdraftLimit(1, "USD", math.MaxInt)
+		if _, err := s.Add(1, "USD", int(from)); err != nil {
+			t.Fatalf("expected the first add to succeed, got %v", err)
+		}
+		if _, err := s.Add(2, "USD", int(to)); err != nil {
+			t.Fatalf("expected the first add to succeed, got %v", err)
+		}
+
+		sent := new(big.Int).Sub(big.NewInt(from), big.NewInt(value))
+		received := new(big.Int).Add(big.NewInt(to), big.NewInt(value))
+		_, err := s.Transfer(1, 2, "USD", int(value))
+
+		fromBalance, _ := s.Get(1, "USD")
+		toBalance, _ := s.Get(2, "USD")
+		if err != nil {
+			if fromBalance != int(from) || toBalance != int(to) {
+				t.Fatalf("expected both values to stay unchanged on %v, got %d and %d", err, fromBalance, toBalance)
+			}
+			if value < 0 && !errors.Is(err, storage.ErrNegativeValue) {
+				t.Fatalf("expected ErrNegativeValue for a negative value, got %v", err)
+			}
+			if value >= 0 && fits(sent) && fits(received) && sent.Cmp(big.NewInt(-math.MaxInt)) >= 0 {
+				t.Fatalf("expected the transfer to succeed, got %v", err)
+			}
+			return
+		}
+
+		if value < 0 {
+			t.Fatalf("expected a transfer of a negative value to be refused, got %d and %d", fromBalance, toBalance)
+		}
+		if big.NewInt(int64(fromBalance)).Cmp(sent) != 0 || big.NewInt(int64(toBalance)).Cmp(received) != 0 {
+			t.Fatalf("expected values %s and %s, got %d and %d", sent, received, fromBalance, toBalance)
+		}
+	})
+}
+
+// FuzzHoldRelease places a hold and settles it by releasing it, or by capturing
+// part of it unless release is set.
+func FuzzHoldRelease(f *testing.F) {
+	f.Add(int64(100), int64(30), int64(0), int64(0), true)
+	f.Add(int64(100), int64(100), int64(math.MaxInt64), int64(0), true)
+	f.Add(int64(0), int64(math.MinInt64), int64(0), int64(0), true)
+	f.Add(int64(100), int64(30), int64(0), int64(20), false)
+	f.Add(int64(100), int64(30), int64(0), int64(0), false)
+	f.Add(int64(100), int64(30), int64(0), int64(31), false)
+	f.Add(int64(100), int64(30), int64(0), int64(-1), false)
+	f.Add(int64(100), int64(100), int64(math.MaxInt64), int64(1), false)
+
+	f.Fuzz(func(t *testing.T, start, value, credit, capture int64, release bool) {
+		s := storage.NewStorage()
+		s.SetOverdraftLimit(1, "USD", math.MaxInt)
+		if _, err := s.Add(1, "USD", int(start)); err != nil {
+			t.Fatalf("expected the first add to succeed, got %v", err)
+		}
+
+		if _, err := s.Hold(1, "USD", 7, int(value)); err != nil {
+			balance, _ := s.Balance(1, "USD")
+			if balance != (storage.Balance{Available: int(start)}) {
+				t.Fatalf("expected a refused hold to keep the balance, got %+v after %v", balance, err)
+			}
+			if value < 0 && !errors.Is(err, storage.ErrNegativeValue) {
+				t.Fatalf("expected ErrNegativeValue for a negative hold, got %v", err)
+			}
+			return
+		}
+		if value < 0 {
+			t.Fatalf("expected a hold of a negative value to be refused")
+		}
+
+		before, _ := s.Balance(1, "USD")
+		if _, err := s.Add(1, "USD", int(credit)); err != nil {
+			return
+		}
+		available := before.Available + int(credit)
+
+		var result int
+		var err error
+		returned := value
+		if release {
+			result, err = s.Release(1, "USD", 7)
+		} else {
+			result, err = s.Capture(1, "USD", 7, int(capture))
+			switch {
+			case capture < 0:
+				if !errors.Is(err, storage.ErrNegativeValue) || result != available {
+					t.Fatalf("expected ErrNegativeValue and value %d, got %d, %v", available, result, err)
+				}
+			case capture > value:
+				if !errors.Is(err, storage.ErrHoldExceeded) || result != available {
+					t.Fatalf("expected ErrHoldExceeded and value %d, got %d, %v", available, result, err)
+				}
+			case capture > 0:
+				returned = value - capture
+			default:
+				returned = 0
+			}
+		}
+
+		balance, _ := s.Balance(1, "USD")
+		if !release && (capture < 0 || capture > value) {
+			if balance.Held != int(value) {
+				t.Fatalf("expected the hold to stay in place on %v, got %+v", err, balance)
+			}
+			return
+		}
+
+		exact := new(big.Int).Add(big.NewInt(int64(available)), big.NewInt(returned))
+		checkResult(t, available, exact, result, err)
+
+		if err != nil && balance.Held != int(value) {
+			t.Fatalf("expected the hold to stay in place on %v, got %+v", err, balance)
+		}
+		if err == nil && balance.Held != 0 {
+			t.Fatalf("expected the hold to be settled, got %+v", balance)
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package storage_test
 
 import (
+	"errors"
+	"math"
+	"sync"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -23,7 +27,7 @@ var _ = Describe("Storage", Label("unit"), func() {
 
 			s := storage.NewStorage()
 
-			result, ok := s.Get(userId)
+			result, ok := s.Get(userId, "USD")
 			Expect(result).To(Equal(amount), "expected amount to be 0 for non-existing user")
 			Expect(ok).To(BeFalse(), "expected ok to be false for non-existing user")
 		})
@@ -35,11 +39,257 @@ var _ = Describe("Storage", Label("unit"), func() {
 			amount := 100
 
 			s := storage.NewStorage()
-			s.Add(userId, amount)
+			s.Add(userId, "USD", amount)
 
-			result, ok := s.Get(userId)
+			result, ok := s.Get(userId, "USD")
 			Expect(result).To(Equal(amount), "expected amount to be correct for existing user")
 			Expect(ok).To(BeTrue(), "expected ok to be true for existing user")
 		})
 	})
+
+	Context("when adding to an existing user", func() {
+		It("should return the resulting amount", func() {
+			userId := 1
+
+			s := storage.NewStorage()
+			s.Add(userId, "USD", 100)
+
+			result, err := s.Add(userId, "USD", 50)
+			Expect(err).NotTo(HaveOccurred(), "expected Add within range to succeed")
+			Expect(result).To(Equal(150), "expected Add to return the resulting amount")
+		})
+	})
+
+	Context("when debiting a user", func() {
+		It("should subtract the amount when the balance covers it", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+
+			result, err := s.Debit(1, "USD", 40)
+			Expect(err).NotTo(HaveOccurred(), "expected the debit to succeed")
+			Expect(result).To(Equal(60), "expected Debit to return the resulting amount")
+		})
+
+		It("should refuse a debit that takes the balance below zero", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+
+			result, err := s.Debit(1, "USD", 101)
+			Expect(err).To(MatchError(storage.ErrInsufficientFunds), "expected the debit to be refused")
+			Expect(result).To(Equal(100), "expected the amount to stay unchanged")
+		})
+
+		It("should allow going below zero down to the overdraft limit", func() {
+			s := storage.NewStorage()
+			s.SetOverdraftLimit(1, "USD", 50)
+
+			result, err := s.Debit(1, "USD", 50)
+			Expect(err).NotTo(HaveOccurred(), "expected a debit within the overdraft limit to succeed")
+			Expect(result).To(Equal(-50), "expected the amount to go below zero")
+
+			_, err = s.Debit(1, "USD", 1)
+			Expect(err).To(MatchError(storage.ErrInsufficientFunds), "expected a debit beyond the overdraft limit to be refused")
+		})
+
+		It("should never exceed the overdraft limit under concurrent debits", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+
+			var wg sync.WaitGroup
+			for range 50 {
+				wg.Go(func() {
+					s.Debit(1, "USD", 10)
+				})
+			}
+			wg.Wait()
+
+			result, _ := s.Get(1, "USD")
+			Expect(result).To(Equal(0), "expected exactly the covered debits to be applied")
+		})
+	})
+
+	Context("when transferring between users", func() {
+		It("should move the amount from one user to the other", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+
+			result, err := s.Transfer(1, 2, "USD", 30)
+			Expect(err).NotTo(HaveOccurred(), "expected the transfer to succeed")
+			Expect(result).To(Equal(70), "expected Transfer to return the resulting amount of the sender")
+
+			received, _ := s.Get(2, "USD")
+			Expect(received).To(Equal(30), "expected the recipient to receive the amount")
+		})
+
+		It("should change neither user when the sender cannot cover the amount", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+
+			result, err := s.Transfer(1, 2, "USD", 101)
+			Expect(err).To(MatchError(storage.ErrInsufficientFunds), "expected the transfer to be refused")
+			Expect(result).To(Equal(100), "expected the sender amount to stay unchanged")
+
+			_, exists := s.Get(2, "USD")
+			Expect(exists).To(BeFalse(), "expected the recipient to stay untouched")
+		})
+	})
+
+	Context("when holding part of a value", func() {
+		It("should move the amount from the available to the held value", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+
+			result, err := s.Hold(1, "USD", 7, 30)
+			Expect(err).NotTo(HaveOccurred(), "expected the hold to succeed")
+			Expect(result).To(Equal(70), "expected Hold to return the available amount")
+
+			balance, ok := s.Balance(1, "USD")
+			Expect(ok).To(BeTrue(), "expected ok to be true for existing user")
+			Expect(balance).To(Equal(storage.Balance{Available: 70, Held: 30}), "expected the amount to be held")
+		})
+
+		It("should refuse a hold beyond the available value or with a used ID", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+
+			_, err := s.Hold(1, "USD", 7, 101)
+			Expect(err).To(MatchError(storage.ErrInsufficientFunds), "expected a hold beyond the available amount to be refused")
+
+			_, err = s.Hold(1, "USD", 7, 10)
+			Expect(err).NotTo(HaveOccurred(), "expected the hold to succeed")
+			_, err = s.Hold(1, "USD", 7, 10)
+			Expect(err).To(MatchError(storage.ErrHoldExists), "expected a hold with a used ID to be refused")
+		})
+
+		It("should spend the captured amount and return the rest", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+			_, _ = s.Hold(1, "USD", 7, 30)
+
+			_, err := s.Capture(1, "USD", 7, 31)
+			Expect(err).To(MatchError(storage.ErrHoldExceeded), "expected capturing more than held to be refused")
+
+			result, err := s.Capture(1, "USD", 7, 20)
+			Expect(err).NotTo(HaveOccurred(), "expected the capture to succeed")
+			Expect(result).To(Equal(80), "expected the uncaptured rest to become available")
+
+			_, err = s.Release(1, "USD", 7)
+			Expect(err).To(MatchError(storage.ErrHoldNotFound), "expected a captured hold to be settled")
+		})
+
+		It("should refuse negative values for debits, transfers, holds and captures", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+			_, _ = s.Hold(1, "USD", 7, 30)
+
+			_, err := s.Debit(1, "USD", -10)
+			Expect(err).To(MatchError(storage.ErrNegativeValue), "expected a negative debit to be refused")
+			_, err = s.Transfer(1, 2, "USD", -10)
+			Expect(err).To(MatchError(storage.ErrNegativeValue), "expected a negative transfer to be refused")
+			_, err = s.Hold(1, "USD", 8, -10)
+			Expect(err).To(MatchError(storage.ErrNegativeValue), "expected a negative hold to be refused")
+			_, err = s.Capture(1, "USD", 7, -10)
+			Expect(err).To(MatchError(storage.ErrNegativeValue), "expected a negative capture to be refused")
+
+			balance, _ := s.Balance(1, "USD")
+			Expect(balance).To(Equal(storage.Balance{Available: 70, Held: 30}), "expected the refused operations to leave the balance unchanged")
+			_, exists := s.Get(2, "USD")
+			Expect(exists).To(BeFalse(), "expected the recipient to stay untouched")
+		})
+
+		It("should only settle holds of the given user", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+			_, _ = s.Hold(1, "USD", 7, 30)
+
+			_, err := s.Release(2, "USD", 7)
+			Expect(err).To(MatchError(storage.ErrHoldNotFound), "expected the hold of another user to be invisible")
+
+			result, err := s.Release(1, "USD", 7)
+			Expect(err).NotTo(HaveOccurred(), "expected the release to succeed")
+			Expect(result).To(Equal(100), "expected the whole hold to become available")
+		})
+	})
+
+	Context("when a user has values in several currencies", func() {
+		It("should keep a separate value per currency", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+			s.Add(1, "EUR", 30)
+
+			_, err := s.Debit(1, "EUR", 50)
+			Expect(err).To(MatchError(storage.ErrInsufficientFunds), "expected the debit to be checked against the EUR value only")
+
+			Expect(s.Balances(1)).To(Equal(map[string]storage.Balance{
+				"USD": {Available: 100},
+				"EUR": {Available: 30},
+			}), "expected one balance per currency")
+			Expect(s.Balances(2)).To(BeEmpty(), "expected no balances for non-existing user")
+		})
+
+		It("should refuse to settle a hold in another currency", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+			_, _ = s.Hold(1, "USD", 7, 30)
+
+			_, err := s.Capture(1, "EUR", 7, 0)
+			Expect(err).To(MatchError(storage.ErrCurrencyMismatch), "expected a capture in another currency to be refused")
+
+			var mismatch *storage.CurrencyMismatchError
+			Expect(errors.As(err, &mismatch)).To(BeTrue(), "expected a CurrencyMismatchError")
+			Expect(*mismatch).To(Equal(storage.CurrencyMismatchError{Expected: "USD", Actual: "EUR"}), "expected both currencies to be reported")
+		})
+	})
+
+	Context("when an operation would overflow", func() {
+		It("should refuse an add beyond the range of an int and keep the value", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", math.MaxInt-10)
+
+			result, err := s.Add(1, "USD", 11)
+			Expect(err).To(MatchError(storage.ErrOverflow), "expected the add to overflow")
+			Expect(result).To(Equal(math.MaxInt-10), "expected Add to return the unchanged amount")
+
+			var overflow *storage.OverflowError
+			Expect(errors.As(err, &overflow)).To(BeTrue(), "expected an OverflowError")
+			Expect(*overflow).To(Equal(storage.OverflowError{Value: math.MaxInt - 10, Op: "+", Operand: 11}), "expected the operation to be reported")
+
+			balance, _ := s.Get(1, "USD")
+			Expect(balance).To(Equal(math.MaxInt-10), "expected the amount to stay unchanged")
+		})
+
+		It("should refuse a debit below the range of an int", func() {
+			s := storage.NewStorage()
+			s.SetOverdraftLimit(1, "USD", math.MaxInt)
+			s.Add(1, "USD", math.MinInt+10)
+
+			result, err := s.Debit(1, "USD", 11)
+			Expect(err).To(MatchError(storage.ErrOverflow), "expected the debit to underflow")
+			Expect(result).To(Equal(math.MinInt+10), "expected the amount to stay unchanged")
+		})
+
+		It("should change neither user when the recipient would overflow", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+			s.Add(2, "USD", math.MaxInt)
+
+			_, err := s.Transfer(1, 2, "USD", 1)
+			Expect(err).To(MatchError(storage.ErrOverflow), "expected the transfer to overflow")
+			Expect(s.Balances(1)).To(Equal(map[string]storage.Balance{"USD": {Available: 100}}), "expected the sender to stay untouched")
+			Expect(s.Balances(2)).To(Equal(map[string]storage.Balance{"USD": {Available: math.MaxInt}}), "expected the recipient to stay untouched")
+		})
+
+		It("should keep a hold when releasing it would overflow", func() {
+			s := storage.NewStorage()
+			s.Add(1, "USD", 100)
+			_, _ = s.Hold(1, "USD", 7, 100)
+			s.Add(1, "USD", math.MaxInt)
+
+			_, err := s.Release(1, "USD", 7)
+			Expect(err).To(MatchError(storage.ErrOverflow), "expected the release to overflow")
+
+			balance, _ := s.Balance(1, "USD")
+			Expect(balance).To(Equal(storage.Balance{Available: math.MaxInt, Held: 100}), "expected the hold to stay in place")
+		})
+	})
 })
@@ -7,6 +7,7 @@ package mock
 import (
 	reflect "reflect"
 
+	storage "github.com/antoniuk-oleksandr/order_processor/internal/storage"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,28 +35,147 @@ func (m *MockUserStorage) EXPECT() *MockUserStorageMockRecorder {
 }
 
 // Add mocks base method.
-func (m *MockUserStorage) Add(ID, value int) {
+func (m *MockUserStorage) Add(ID int, currency string, value int) (int, error) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Add", ID, value)
+	ret := m.ctrl.Call(m, "Add", ID, currency, value)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Add indicates an expected call of Add.
-func (mr *MockUserStorageMockRecorder) Add(ID, value interface{}) *gomock.Call {
+func (mr *MockUserStorageMockRecorder) Add(ID, currency, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockUserStorage)(nil).Add), ID, currency, value)
+}
+
+// Balance mocks base method.
+func (m *MockUserStorage) Balance(ID int, currency string) (storage.Balance, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Balance", ID, currency)
+	ret0, _ := ret[0].(storage.Balance)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Balance indicates an expected call of Balance.
+func (mr *MockUserStorageMockRecorder) Balance(ID, currency interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balance", reflect.TypeOf((*MockUserStorage)(nil).Balance), ID, currency)
+}
+
+// Balances mocks base method.
+func (m *MockUserStorage) Balances(ID int) map[string]storage.Balance {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Balances", ID)
+	ret0, _ := ret[0].(map[string]storage.Balance)
+	return ret0
+}
+
+// Balances indicates an expected call of Balances.
+func (mr *MockUserStorageMockRecorder) Balances(ID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balances", reflect.TypeOf((*MockUserStorage)(nil).Balances), ID)
+}
+
+// Capture mocks base method.
+func (m *MockUserStorage) Capture(ID int, currency string, holdID, value int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Capture", ID, currency, holdID, value)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Capture indicates an expected call of Capture.
+func (mr *MockUserStorageMockRecorder) Capture(ID, currency, holdID, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Capture", reflect.TypeOf((*MockUserStorage)(nil).Capture), ID, currency, holdID, value)
+}
+
+// Debit mocks base method.
+func (m *MockUserStorage) Debit(ID int, currency string, value int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Debit", ID, currency, value)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Debit indicates an expected call of Debit.
+func (mr *MockUserStorageMockRecorder) Debit(ID, currency, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockUserStorage)(nil).Add), ID, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Debit", reflect.TypeOf((*MockUserStorage)(nil).Debit), ID, currency, value)
 }
 
 // Get mocks base method.
-func (m *MockUserStorage) Get(ID int) (int, bool) {
+func (m *MockUserStorage) Get(ID int, currency string) (int, bool) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", ID)
+	ret := m.ctrl.Call(m, "Get", ID, currency)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(bool)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get.
-func (mr *MockUserStorageMockRecorder) Get(ID interface{}) *gomock.Call {
+func (mr *MockUserStorageMockRecorder) Get(ID, currency interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUserStorage)(nil).Get), ID, currency)
+}
+
+// Hold mocks base method.
+func (m *MockUserStorage) Hold(ID int, currency string, holdID, value int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hold", ID, currency, holdID, value)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hold indicates an expected call of Hold.
+func (mr *MockUserStorageMockRecorder) Hold(ID, currency, holdID, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hold", reflect.TypeOf((*MockUserStorage)(nil).Hold), ID, currency, holdID, value)
+}
+
+// Release mocks base method.
+func (m *MockUserStorage) Release(ID int, currency string, holdID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ID, currency, holdID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockUserStorageMockRecorder) Release(ID, currency, holdID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockUserStorage)(nil).Release), ID, currency, holdID)
+}
+
+// SetOverdraftLimit mocks base method.
+func (m *MockUserStorage) SetOverdraftLimit(ID int, currency string, limit int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetOverdraftLimit", ID, currency, limit)
+}
+
+// SetOverdraftLimit indicates an expected call of SetOverdraftLimit.
+func (mr *MockUserStorageMockRecorder) SetOverdraftLimit(ID, currency, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOverdraftLimit", reflect.TypeOf((*MockUserStorage)(nil).SetOverdraftLimit), ID, currency, limit)
+}
+
+// Transfer mocks base method.
+func (m *MockUserStorage) Transfer(from, to int, currency string, value int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", from, to, currency, value)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockUserStorageMockRecorder) Transfer(from, to, currency, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUserStorage)(nil).Get), ID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockUserStorage)(nil).Transfer), from, to, currency, value)
 }